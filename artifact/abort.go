@@ -0,0 +1,41 @@
+package artifact
+
+import (
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrAborted is returned when reading from an aborted artifact
+	ErrAborted = fmt.Errorf("artifact upload aborted")
+)
+
+type abortableReader struct {
+	r     io.Reader
+	abort chan struct{}
+}
+
+func (ar *abortableReader) Read(p []byte) (int, error) {
+	select {
+	case <-ar.abort:
+		return 0, ErrAborted
+	default:
+		return ar.r.Read(p)
+	}
+}
+
+// Abort causes all current and future readers of the artifact to
+// fail with ErrAborted
+func (a *Artifact) Abort() {
+	a.abortOnce.Do(func() { close(a.abort) })
+}
+
+// Aborted tells if the artifact has been aborted
+func (a *Artifact) Aborted() bool {
+	select {
+	case <-a.abort:
+		return true
+	default:
+		return false
+	}
+}
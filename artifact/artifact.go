@@ -9,6 +9,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/mitchellh/goamz/s3"
 )
@@ -30,12 +31,28 @@ type Artifact struct {
 	Prefix string
 	Perm   s3.ACL
 
+	CacheControl        string
+	ContentTypeOverride string
+	ContentDisposition  string
+
+	// SymlinkTarget is the target of a symlink that is uploaded as a
+	// symlink rather than as the file it points to
+	SymlinkTarget string
+
 	UploadResult *Result
+
+	abort     chan struct{}
+	abortOnce sync.Once
+	wrappers  []ReaderWrapper
 }
 
+// ReaderWrapper wraps each io.Reader returned by an artifact, e.g. to
+// count or transform the bytes read from it
+type ReaderWrapper func(io.Reader) io.Reader
+
 // New creates a new *Artifact
 func New(prefix, source, dest string, opts *Options) *Artifact {
-	return &Artifact{
+	a := &Artifact{
 		Prefix: prefix,
 		Source: source,
 		Dest:   dest,
@@ -47,12 +64,24 @@ func New(prefix, source, dest string, opts *Options) *Artifact {
 		JobID:       opts.JobID,
 		Perm:        opts.Perm,
 
+		CacheControl:        opts.CacheControl,
+		ContentTypeOverride: opts.ContentType,
+
 		UploadResult: &Result{},
+
+		abort: make(chan struct{}),
 	}
+
+	opts.Rules.apply(a)
+	return a
 }
 
 // ContentType makes it easier to find the perfect match
 func (a *Artifact) ContentType() string {
+	if a.ContentTypeOverride != "" {
+		return a.ContentTypeOverride
+	}
+
 	ctype := mime.TypeByExtension(path.Ext(a.Source))
 	if ctype != "" {
 		return ctype
@@ -75,16 +104,35 @@ func (a *Artifact) ContentType() string {
 
 // Reader makes an io.Reader out of the filepath
 func (a *Artifact) Reader() (io.Reader, error) {
-	f, err := os.Open(a.Source)
-	if err != nil {
-		return nil, err
+	var f io.Reader = bytes.NewReader([]byte{})
+	if a.SymlinkTarget == "" {
+		file, err := os.Open(a.Source)
+		if err != nil {
+			return nil, err
+		}
+		f = file
 	}
 
-	return f, nil
+	var reader io.Reader = &abortableReader{r: f, abort: a.abort}
+	for _, w := range a.wrappers {
+		reader = w(reader)
+	}
+
+	return reader, nil
+}
+
+// AddReaderWrapper adds a wrapper to be applied to every subsequent
+// call to Reader
+func (a *Artifact) AddReaderWrapper(w ReaderWrapper) {
+	a.wrappers = append(a.wrappers, w)
 }
 
 // Size reports the size of the artifact
 func (a *Artifact) Size() (uint64, error) {
+	if a.SymlinkTarget != "" {
+		return uint64(0), nil
+	}
+
 	fi, err := os.Stat(a.Source)
 	if err != nil {
 		return uint64(0), nil
@@ -97,3 +145,14 @@ func (a *Artifact) Size() (uint64, error) {
 func (a *Artifact) FullDest() string {
 	return strings.TrimLeft(filepath.Join(a.Prefix, a.Dest), "/")
 }
+
+// RedirectLocation is the remote path a preserved symlink redirects to,
+// which is only known for relative link targets
+func (a *Artifact) RedirectLocation() string {
+	target := filepath.ToSlash(a.SymlinkTarget)
+	if target == "" || path.IsAbs(target) || filepath.IsAbs(a.SymlinkTarget) {
+		return ""
+	}
+
+	return "/" + strings.TrimLeft(path.Join(a.Prefix, path.Dir(filepath.ToSlash(a.Dest)), target), "/")
+}
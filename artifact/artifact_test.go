@@ -2,6 +2,7 @@ package artifact
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -152,3 +153,98 @@ func TestArtifactReader(t *testing.T) {
 		}
 	}
 }
+
+func TestArtifactAbort(t *testing.T) {
+	a := New("bucket", testArtifactPaths[0].Path, "linux/foo", &Options{
+		Perm:     s3.PublicRead,
+		RepoSlug: "owner/foo",
+	})
+
+	if a.Aborted() {
+		t.Fatalf("new artifact is aborted")
+	}
+
+	reader, err := a.Reader()
+	if err != nil {
+		t.Fatalf("error getting reader: %v", err)
+	}
+
+	a.Abort()
+	a.Abort()
+
+	if !a.Aborted() {
+		t.Fatalf("artifact not aborted")
+	}
+
+	_, err = ioutil.ReadAll(reader)
+	if err != ErrAborted {
+		t.Fatalf("reading aborted artifact did not fail: %v", err)
+	}
+}
+
+func TestArtifactAddReaderWrapper(t *testing.T) {
+	a := New("bucket", testArtifactPaths[0].Path, "linux/foo", &Options{
+		Perm:     s3.PublicRead,
+		RepoSlug: "owner/foo",
+	})
+
+	wrapped := 0
+	a.AddReaderWrapper(func(r io.Reader) io.Reader {
+		wrapped++
+		return io.LimitReader(r, 4)
+	})
+
+	reader, err := a.Reader()
+	if err != nil {
+		t.Fatalf("error getting reader: %v", err)
+	}
+
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("error reading: %v", err)
+	}
+
+	if string(b) != "some" {
+		t.Fatalf("wrapped reader read %q != %q", string(b), "some")
+	}
+
+	if wrapped != 1 {
+		t.Fatalf("wrapper called %v times != 1", wrapped)
+	}
+}
+
+func TestArtifactContentTypeOverride(t *testing.T) {
+	a := New("bucket", testArtifactPaths[1].Path, "linux/foo", &Options{
+		Perm:         s3.PublicRead,
+		RepoSlug:     "owner/foo",
+		CacheControl: "public, max-age=60",
+		ContentType:  "text/plain; charset=utf-8",
+	})
+
+	if a.ContentType() != "text/plain; charset=utf-8" {
+		t.Fatalf("content type override ignored: %v", a.ContentType())
+	}
+
+	if a.CacheControl != "public, max-age=60" {
+		t.Fatalf("cache control not set correctly: %v", a.CacheControl)
+	}
+}
+
+func TestArtifactSymlink(t *testing.T) {
+	a := New("bucket/1", filepath.Join(testArtifactPathDir, "foo"), "docs/latest", &Options{})
+	a.SymlinkTarget = "../v1.2/index.html"
+
+	size, err := a.Size()
+	if err != nil || size != uint64(0) {
+		t.Fatalf("symlink size %v != 0 (err %v)", size, err)
+	}
+
+	if a.RedirectLocation() != "/bucket/1/v1.2/index.html" {
+		t.Fatalf("redirect location %v != /bucket/1/v1.2/index.html", a.RedirectLocation())
+	}
+
+	a.SymlinkTarget = "/etc/passwd"
+	if a.RedirectLocation() != "" {
+		t.Fatalf("absolute symlink target redirected to %v", a.RedirectLocation())
+	}
+}
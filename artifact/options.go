@@ -12,4 +12,8 @@ type Options struct {
 	JobNumber   string
 	JobID       string
 	Perm        s3.ACL
+
+	CacheControl string
+	ContentType  string
+	Rules        Rules
 }
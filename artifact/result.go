@@ -1,7 +1,14 @@
 package artifact
 
+import "time"
+
 // Result contains some lame simple crap about things done with artifacts
 type Result struct {
-	OK  bool
-	Err error
+	OK       bool
+	Err      error
+	Retries  uint64
+	Duration time.Duration
+	Provider string
+	// Checksum is the SHA-256 of the source, if it was read in full
+	Checksum string
 }
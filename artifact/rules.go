@@ -0,0 +1,196 @@
+package artifact
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// RuleKeys are the headers a rule may set, as given after the '?'
+	// in a rule spec, e.g. "*.log?content-type=text/plain"
+	RuleKeys = []string{"content-type", "content-disposition", "cache-control"}
+
+	ruleEscaper = strings.NewReplacer("%", "%25", "&", "%26", ":", "%3A", "?", "%3F")
+)
+
+// Rule sets headers for the artifacts matching its pattern.  The
+// pattern may be an extension like ".log", a glob matched against the
+// base name like "*.junit" or, if it contains a '/', a glob matched
+// against the whole destination path like "reports/*.xml".  An empty
+// pattern matches everything.
+type Rule struct {
+	Pattern            string
+	ContentType        string
+	ContentDisposition string
+	CacheControl       string
+}
+
+// Rules are consulted in order, with the first matching rule that sets
+// a given header winning
+type Rules []*Rule
+
+// ParseRule makes a *Rule from a spec of the form
+// "pattern?key=value&...", where each key is one of RuleKeys.  The
+// last '?' starts the headers, so the pattern may itself contain '?'.
+func ParseRule(spec string) (*Rule, error) {
+	idx := strings.LastIndex(spec, "?")
+	if idx < 0 || strings.TrimSpace(spec[idx+1:]) == "" {
+		return nil, fmt.Errorf("rule %q: no headers given", spec)
+	}
+
+	r := &Rule{Pattern: strings.TrimSpace(spec[:idx])}
+	if _, err := path.Match(r.Pattern, ""); err != nil {
+		return nil, fmt.Errorf("rule %q: %v", spec, err)
+	}
+
+	for _, setting := range strings.Split(spec[idx+1:], "&") {
+		if strings.TrimSpace(setting) == "" {
+			continue
+		}
+
+		kv := strings.SplitN(setting, "=", 2)
+		if len(kv) < 2 {
+			return nil, fmt.Errorf("rule %q: setting %q has no value", spec, setting)
+		}
+
+		value, err := url.PathUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", spec, err)
+		}
+
+		switch strings.TrimSpace(kv[0]) {
+		case "content-type":
+			r.ContentType = value
+		case "content-disposition":
+			r.ContentDisposition = value
+		case "cache-control":
+			r.CacheControl = value
+		default:
+			return nil, fmt.Errorf("rule %q: unknown header %q", spec, kv[0])
+		}
+	}
+
+	return r, nil
+}
+
+// String makes the spec that ParseRule would turn back into r
+func (r *Rule) String() string {
+	values := map[string]string{
+		"content-type":        r.ContentType,
+		"content-disposition": r.ContentDisposition,
+		"cache-control":       r.CacheControl,
+	}
+
+	parts := []string{}
+	for _, key := range RuleKeys {
+		if values[key] != "" {
+			parts = append(parts, fmt.Sprintf("%s=%s", key, ruleEscaper.Replace(values[key])))
+		}
+	}
+
+	return fmt.Sprintf("%s?%s", r.Pattern, strings.Join(parts, "&"))
+}
+
+// Matches tells if the rule applies to the given destination path
+func (r *Rule) Matches(dest string) bool {
+	dest = filepath.ToSlash(dest)
+
+	switch {
+	case r.Pattern == "":
+		return true
+	case strings.HasPrefix(r.Pattern, ".") && !strings.ContainsAny(r.Pattern, "*?[/"):
+		return strings.EqualFold(path.Ext(dest), r.Pattern)
+	case strings.Contains(r.Pattern, "/"):
+		matched, _ := path.Match(strings.TrimLeft(r.Pattern, "/"), strings.TrimLeft(dest, "/"))
+		return matched
+	default:
+		matched, _ := path.Match(r.Pattern, path.Base(dest))
+		return matched
+	}
+}
+
+// LoadMimeTypes makes rules from a mime.types-style file, where each
+// line is a content type followed by the extensions it applies to.
+// Parameters may be given without spaces, e.g. "text/plain;charset=utf-8".
+func LoadMimeTypes(filename string) (Rules, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules := Rules{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx > -1 {
+			line = line[:idx]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		for _, ext := range fields[1:] {
+			rules = append(rules, &Rule{
+				Pattern:     "." + strings.TrimLeft(ext, "."),
+				ContentType: fields[0],
+			})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (rules Rules) apply(a *Artifact) {
+	ctype, disposition, cacheControl := "", "", ""
+
+	for _, r := range rules {
+		if !r.Matches(a.Dest) {
+			continue
+		}
+
+		if ctype == "" {
+			ctype = r.ContentType
+		}
+		if disposition == "" {
+			disposition = r.ContentDisposition
+		}
+		if cacheControl == "" {
+			cacheControl = r.CacheControl
+		}
+	}
+
+	if a.ContentTypeOverride == "" {
+		a.ContentTypeOverride = ctype
+	}
+
+	if cacheControl != "" {
+		a.CacheControl = cacheControl
+	}
+
+	if disposition != "" {
+		a.ContentDisposition = contentDisposition(disposition, a.Dest)
+	}
+}
+
+// contentDisposition adds the file name to a bare "inline" or
+// "attachment" disposition
+func contentDisposition(disposition, dest string) string {
+	switch strings.ToLower(disposition) {
+	case "inline", "attachment":
+		return fmt.Sprintf("%s; filename=%q", disposition, path.Base(filepath.ToSlash(dest)))
+	default:
+		return disposition
+	}
+}
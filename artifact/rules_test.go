@@ -0,0 +1,133 @@
+package artifact
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	r, err := ParseRule("*.log?content-type=text/plain; charset=utf-8&content-disposition=inline")
+	if err != nil {
+		t.Fatalf("failed to parse rule: %v", err)
+	}
+
+	if r.Pattern != "*.log" {
+		t.Fatalf("pattern %v != *.log", r.Pattern)
+	}
+
+	if r.ContentType != "text/plain; charset=utf-8" {
+		t.Fatalf("content type %v != text/plain; charset=utf-8", r.ContentType)
+	}
+
+	if r.ContentDisposition != "inline" {
+		t.Fatalf("content disposition %v != inline", r.ContentDisposition)
+	}
+
+	r, err = ParseRule("build-?.log?cache-control=no-cache")
+	if err != nil || r.Pattern != "build-?.log" {
+		t.Fatalf("failed to parse rule with '?' in pattern: %v %v", r, err)
+	}
+
+	for _, spec := range []string{"*.log", "*.log?owner=me", "[?content-type=text/plain"} {
+		if _, err := ParseRule(spec); err == nil {
+			t.Fatalf("invalid rule %q was parsed", spec)
+		}
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	r := &Rule{Pattern: ".junit", ContentType: "application/xml", CacheControl: "max-age=60"}
+
+	parsed, err := ParseRule(r.String())
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", r.String(), err)
+	}
+
+	if *parsed != *r {
+		t.Fatalf("%v != %v", parsed, r)
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	for pattern, dests := range map[string]map[string]bool{
+		"": map[string]bool{"foo": true},
+		".log": map[string]bool{
+			"build.log":      true,
+			"logs/BUILD.LOG": true,
+			"build.logs":     false,
+		},
+		"*.junit": map[string]bool{
+			"test.junit":         true,
+			"reports/test.junit": true,
+			"test.xml":           false,
+		},
+		"reports/*.xml": map[string]bool{
+			"reports/test.xml":    true,
+			"/reports/test.xml":   true,
+			"other/reports/a.xml": false,
+		},
+	} {
+		r := &Rule{Pattern: pattern}
+		for dest, expected := range dests {
+			if r.Matches(dest) != expected {
+				t.Fatalf("pattern %q matching %q != %v", pattern, dest, expected)
+			}
+		}
+	}
+}
+
+func TestLoadMimeTypes(t *testing.T) {
+	filename := filepath.Join(testTmp, "mime.types")
+	err := ioutil.WriteFile(filename, []byte(`
+# comments are ignored
+text/plain;charset=utf-8	log out
+application/xml junit
+empty/type
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadMimeTypes(filename)
+	if err != nil {
+		t.Fatalf("failed to load mime types: %v", err)
+	}
+
+	if len(rules) != 3 {
+		t.Fatalf("rules length %v != 3", len(rules))
+	}
+
+	if rules[2].Pattern != ".junit" || rules[2].ContentType != "application/xml" {
+		t.Fatalf("unexpected rule %v", rules[2])
+	}
+}
+
+func TestNewArtifactRules(t *testing.T) {
+	opts := &Options{
+		CacheControl: "private",
+		Rules: Rules{
+			&Rule{Pattern: ".log", ContentType: "text/plain; charset=utf-8"},
+			&Rule{Pattern: "*", ContentType: "text/html", ContentDisposition: "attachment", CacheControl: "no-cache"},
+		},
+	}
+
+	a := New("bucket", "/tmp/build.log", "logs/build.log", opts)
+	if a.ContentType() != "text/plain; charset=utf-8" {
+		t.Fatalf("content type %v != text/plain; charset=utf-8", a.ContentType())
+	}
+
+	if a.ContentDisposition != `attachment; filename="build.log"` {
+		t.Fatalf("content disposition %v != attachment; filename=\"build.log\"", a.ContentDisposition)
+	}
+
+	if a.CacheControl != "no-cache" {
+		t.Fatalf("cache control %v != no-cache", a.CacheControl)
+	}
+
+	opts.ContentType = "text/x-log"
+	a = New("bucket", "/tmp/build.log", "logs/build.log", opts)
+	if a.ContentType() != "text/x-log" {
+		t.Fatalf("content type %v != text/x-log", a.ContentType())
+	}
+}
@@ -6,7 +6,10 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
+	"github.com/travis-ci/artifacts/download"
 	"github.com/travis-ci/artifacts/logging"
+	"github.com/travis-ci/artifacts/promote"
+	"github.com/travis-ci/artifacts/prune"
 	"github.com/travis-ci/artifacts/upload"
 )
 
@@ -31,7 +34,7 @@ func buildApp() *cli.App {
 		cli.StringFlag{
 			Name:   "log-format, f",
 			EnvVar: "ARTIFACTS_LOG_FORMAT",
-			Usage:  "log output format (text, json, or multiline)",
+			Usage:  "log output format (text, json, multiline, travis, github, gitlab, or auto to pick the CI's own)",
 		},
 		cli.BoolFlag{
 			Name:   "debug, D",
@@ -43,6 +46,11 @@ func buildApp() *cli.App {
 			EnvVar: "ARTIFACTS_QUIET",
 			Usage:  "set log level to panic",
 		},
+		cli.BoolFlag{
+			Name:   "no-redact",
+			EnvVar: "ARTIFACTS_NO_REDACT",
+			Usage:  "do not redact credentials and signatures from log output",
+		},
 	}
 	app.Commands = []cli.Command{
 		{
@@ -53,6 +61,28 @@ func buildApp() *cli.App {
 			Flags:       upload.DefaultOptions.Flags(),
 			Action:      runUpload,
 		},
+		{
+			Name:        "download",
+			ShortName:   "d",
+			Usage:       "download some artifacts!",
+			Description: download.CommandDescription,
+			Flags:       download.Flags(),
+			Action:      runDownload,
+		},
+		{
+			Name:        "prune",
+			Usage:       "delete old artifacts!",
+			Description: prune.CommandDescription,
+			Flags:       prune.Flags(),
+			Action:      runPrune,
+		},
+		{
+			Name:        "promote",
+			Usage:       "copy some artifacts to another prefix!",
+			Description: promote.CommandDescription,
+			Flags:       promote.Flags(),
+			Action:      runPromote,
+		},
 	}
 
 	return app
@@ -64,6 +94,12 @@ func runUpload(c *cli.Context) {
 	opts := upload.NewOptions()
 	opts.UpdateFromCLI(c)
 
+	if err := opts.UpdateFromConfigFile(); err != nil {
+		log.Fatal(err)
+	}
+
+	logging.AddSecrets(log, opts.Secrets()...)
+
 	if err := opts.Validate(); err != nil {
 		log.Fatal(err)
 	}
@@ -73,16 +109,66 @@ func runUpload(c *cli.Context) {
 	}
 }
 
+func runDownload(c *cli.Context) {
+	log := configureLog(c)
+
+	opts := download.NewOptions()
+	opts.UpdateFromCLI(c)
+	logging.AddSecrets(log, opts.Secrets()...)
+
+	if err := opts.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := download.Download(opts, log); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runPrune(c *cli.Context) {
+	log := configureLog(c)
+
+	opts := prune.NewOptions()
+	if err := opts.UpdateFromCLI(c); err != nil {
+		log.Fatal(err)
+	}
+
+	logging.AddSecrets(log, opts.Secrets()...)
+
+	if err := opts.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := prune.Prune(opts, log); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runPromote(c *cli.Context) {
+	log := configureLog(c)
+
+	opts := promote.NewOptions()
+	if err := opts.UpdateFromCLI(c); err != nil {
+		log.Fatal(err)
+	}
+
+	logging.AddSecrets(log, opts.Secrets()...)
+
+	if err := opts.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := promote.Promote(opts, log); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func configureLog(c *cli.Context) *logrus.Logger {
 	log := logrus.New()
 
-	switch c.GlobalString("log-format") {
-	case "json":
-		log.Formatter = &logrus.JSONFormatter{}
-	case "multiline":
-		log.Formatter = &logging.MultiLineFormatter{}
-	default:
-		log.Formatter = &logrus.TextFormatter{}
+	log.Formatter = logging.NewFormatter(c.GlobalString("log-format"))
+	if !c.GlobalBool("no-redact") {
+		log.Formatter = logging.NewRedactingFormatter(log.Formatter)
 	}
 
 	if c.GlobalBool("debug") {
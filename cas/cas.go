@@ -0,0 +1,97 @@
+package cas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	// BlobHeader is the metadata header on a pointer object that holds
+	// the key of the blob it points to
+	BlobHeader = "x-amz-meta-cas-blob"
+	// SHA256Header is the metadata header on a pointer object that holds
+	// the hex-encoded SHA-256 of the blob it points to
+	SHA256Header = "x-amz-meta-cas-sha256"
+	// RedirectHeader is the header that makes a pointer object redirect
+	// to its blob when the bucket is served as a website
+	RedirectHeader = "x-amz-website-redirect-location"
+)
+
+// BlobKey returns the key a blob with the given SHA-256 is stored
+// under, e.g. "cas/sha256/ab/abcdef..."
+func BlobKey(prefix, sum string) string {
+	return strings.TrimLeft(path.Join(prefix, "sha256", sum[:2], sum), "/")
+}
+
+// PointerHeaders returns the headers that make an object a pointer to
+// the blob
+func PointerHeaders(blobKey, sum string) map[string][]string {
+	return map[string][]string{
+		BlobHeader:     []string{blobKey},
+		SHA256Header:   []string{sum},
+		RedirectHeader: []string{"/" + blobKey},
+	}
+}
+
+// Resolve returns the blob key and SHA-256 a pointer object's headers
+// point to, or empty strings if the headers aren't a pointer's
+func Resolve(header http.Header) (string, string) {
+	return header.Get(BlobHeader), header.Get(SHA256Header)
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 of the file
+func FileSHA256(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Verifier checks that the bytes read through it have the expected
+// SHA-256
+type Verifier struct {
+	r        io.Reader
+	expected string
+	h        hashWriter
+}
+
+type hashWriter interface {
+	io.Writer
+	Sum([]byte) []byte
+}
+
+// NewVerifier wraps r to compute the SHA-256 of what is read from it
+func NewVerifier(r io.Reader, expected string) *Verifier {
+	return &Verifier{r: r, expected: expected, h: sha256.New()}
+}
+
+func (v *Verifier) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	return n, err
+}
+
+// Verify returns an error if the bytes read so far don't have the
+// expected SHA-256
+func (v *Verifier) Verify() error {
+	actual := hex.EncodeToString(v.h.Sum(nil))
+	if actual != v.expected {
+		return fmt.Errorf("sha256 mismatch: expected %s, got %s", v.expected, actual)
+	}
+	return nil
+}
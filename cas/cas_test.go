@@ -0,0 +1,50 @@
+package cas
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+const (
+	helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+)
+
+func TestBlobKey(t *testing.T) {
+	key := BlobKey("/artifacts/cas", helloSHA256)
+	if key != "artifacts/cas/sha256/2c/"+helloSHA256 {
+		t.Fatalf("unexpected blob key %v", key)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	header := http.Header{}
+	for k, v := range PointerHeaders("cas/sha256/2c/"+helloSHA256, helloSHA256) {
+		header.Set(k, v[0])
+	}
+
+	blobKey, sum := Resolve(header)
+	if blobKey != "cas/sha256/2c/"+helloSHA256 || sum != helloSHA256 {
+		t.Fatalf("unexpected pointer %v %v", blobKey, sum)
+	}
+
+	blobKey, _ = Resolve(http.Header{})
+	if blobKey != "" {
+		t.Fatalf("plain object resolved to %v", blobKey)
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	v := NewVerifier(bytes.NewBufferString("hello"), helloSHA256)
+	ioutil.ReadAll(v)
+	if err := v.Verify(); err != nil {
+		t.Fatalf("verification failed: %v", err)
+	}
+
+	v = NewVerifier(bytes.NewBufferString("hellp"), helloSHA256)
+	ioutil.ReadAll(v)
+	if v.Verify() == nil {
+		t.Fatalf("verification of wrong content passed")
+	}
+}
@@ -0,0 +1,214 @@
+package ci
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CI is a detected CI provider along with the build context found in
+// its native env vars, keyed by the name of the option it fills in,
+// e.g. "RepoSlug"
+type CI struct {
+	Name   string
+	Values map[string]string
+}
+
+type provider struct {
+	name   string
+	detect func(getenv func(string) string) bool
+	values func(getenv func(string) string) map[string]string
+}
+
+var (
+	providers = []*provider{
+		{
+			name:   "travis",
+			detect: isTrue("TRAVIS"),
+			// the options already fall back to the TRAVIS_* vars
+			values: func(getenv func(string) string) map[string]string { return map[string]string{} },
+		},
+		{name: "github-actions", detect: isTrue("GITHUB_ACTIONS"), values: githubActions},
+		{name: "gitlab", detect: isTrue("GITLAB_CI"), values: gitlab},
+		{name: "circleci", detect: isTrue("CIRCLECI"), values: circleCI},
+		{name: "buildkite", detect: isTrue("BUILDKITE"), values: buildkite},
+		{name: "jenkins", detect: isSet("JENKINS_URL"), values: jenkins},
+	}
+)
+
+// Detect returns the CI provider the process is running on, if any
+func Detect() *CI {
+	return detect(func(key string) string {
+		return strings.TrimSpace(os.Getenv(key))
+	})
+}
+
+func detect(getenv func(string) string) *CI {
+	for _, p := range providers {
+		if !p.detect(getenv) {
+			continue
+		}
+
+		values := map[string]string{}
+		for name, value := range p.values(getenv) {
+			if value != "" {
+				values[name] = value
+			}
+		}
+
+		return &CI{Name: p.name, Values: values}
+	}
+
+	return nil
+}
+
+// Value returns the value for the option, if any.  It is safe to call
+// on a nil *CI.
+func (c *CI) Value(name string) string {
+	if c == nil {
+		return ""
+	}
+	return c.Values[name]
+}
+
+func githubActions(getenv func(string) string) map[string]string {
+	values := map[string]string{
+		"RepoSlug":    getenv("GITHUB_REPOSITORY"),
+		"BuildNumber": getenv("GITHUB_RUN_NUMBER"),
+		"BuildID":     getenv("GITHUB_RUN_ID"),
+		"JobNumber":   getenv("GITHUB_JOB"),
+		"Commit":      getenv("GITHUB_SHA"),
+		"OS":          strings.ToLower(getenv("RUNNER_OS")),
+		"WorkingDir":  getenv("GITHUB_WORKSPACE"),
+	}
+
+	// GITHUB_JOB is only the job's name in the workflow, so re-runs at
+	// least get their own job number.  Matrix legs share it all the same
+	// and need --job-number, as no env var tells them apart.
+	if attempt := getenv("GITHUB_RUN_ATTEMPT"); values["JobNumber"] != "" && attempt != "" {
+		values["JobNumber"] += "." + attempt
+	}
+
+	ref := getenv("GITHUB_REF")
+	switch {
+	case strings.HasPrefix(ref, "refs/pull/"):
+		values["PullRequest"] = strings.Split(strings.TrimPrefix(ref, "refs/pull/"), "/")[0]
+		values["Branch"] = getenv("GITHUB_HEAD_REF")
+	case strings.HasPrefix(ref, "refs/tags/"):
+		values["Tag"] = strings.TrimPrefix(ref, "refs/tags/")
+		values["Branch"] = values["Tag"]
+	default:
+		values["Branch"] = strings.TrimPrefix(ref, "refs/heads/")
+	}
+
+	return values
+}
+
+func gitlab(getenv func(string) string) map[string]string {
+	values := map[string]string{
+		"RepoSlug":    getenv("CI_PROJECT_PATH"),
+		"BuildNumber": getenv("CI_PIPELINE_IID"),
+		"BuildID":     getenv("CI_PIPELINE_ID"),
+		"JobNumber":   getenv("CI_JOB_ID"),
+		"JobID":       getenv("CI_JOB_ID"),
+		"Branch":      firstOf(getenv, "CI_MERGE_REQUEST_SOURCE_BRANCH_NAME", "CI_COMMIT_BRANCH", "CI_COMMIT_REF_NAME"),
+		"Tag":         getenv("CI_COMMIT_TAG"),
+		"Commit":      getenv("CI_COMMIT_SHA"),
+		"WorkingDir":  getenv("CI_PROJECT_DIR"),
+		"PullRequest": getenv("CI_MERGE_REQUEST_IID"),
+	}
+
+	return values
+}
+
+func circleCI(getenv func(string) string) map[string]string {
+	values := map[string]string{
+		"BuildNumber": getenv("CIRCLE_BUILD_NUM"),
+		"BuildID":     getenv("CIRCLE_WORKFLOW_ID"),
+		"JobNumber":   getenv("CIRCLE_JOB"),
+		"JobID":       getenv("CIRCLE_WORKFLOW_JOB_ID"),
+		"Branch":      getenv("CIRCLE_BRANCH"),
+		"Tag":         getenv("CIRCLE_TAG"),
+		"Commit":      getenv("CIRCLE_SHA1"),
+		"WorkingDir":  getenv("CIRCLE_WORKING_DIRECTORY"),
+	}
+
+	if user, repo := getenv("CIRCLE_PROJECT_USERNAME"), getenv("CIRCLE_PROJECT_REPONAME"); user != "" && repo != "" {
+		values["RepoSlug"] = user + "/" + repo
+	}
+
+	if pr := getenv("CIRCLE_PR_NUMBER"); pr != "" {
+		values["PullRequest"] = pr
+	} else if url := getenv("CIRCLE_PULL_REQUEST"); url != "" {
+		values["PullRequest"] = url[strings.LastIndex(url, "/")+1:]
+	}
+
+	// the working directory is usually given as "~/project"
+	if dir := values["WorkingDir"]; strings.HasPrefix(dir, "~/") {
+		values["WorkingDir"] = filepath.Join(getenv("HOME"), dir[2:])
+	}
+
+	return values
+}
+
+func buildkite(getenv func(string) string) map[string]string {
+	values := map[string]string{
+		"BuildNumber": getenv("BUILDKITE_BUILD_NUMBER"),
+		"BuildID":     getenv("BUILDKITE_BUILD_ID"),
+		"JobNumber":   getenv("BUILDKITE_JOB_ID"),
+		"JobID":       getenv("BUILDKITE_JOB_ID"),
+		"Branch":      getenv("BUILDKITE_BRANCH"),
+		"Tag":         getenv("BUILDKITE_TAG"),
+		"Commit":      getenv("BUILDKITE_COMMIT"),
+		"WorkingDir":  getenv("BUILDKITE_BUILD_CHECKOUT_PATH"),
+		"PullRequest": getenv("BUILDKITE_PULL_REQUEST"),
+	}
+
+	if org, pipeline := getenv("BUILDKITE_ORGANIZATION_SLUG"), getenv("BUILDKITE_PIPELINE_SLUG"); org != "" && pipeline != "" {
+		values["RepoSlug"] = org + "/" + pipeline
+	}
+
+	return values
+}
+
+func jenkins(getenv func(string) string) map[string]string {
+	values := map[string]string{
+		"RepoSlug":    getenv("JOB_NAME"),
+		"BuildNumber": getenv("BUILD_NUMBER"),
+		"BuildID":     getenv("BUILD_ID"),
+		"JobNumber":   getenv("BUILD_NUMBER"),
+		"JobID":       getenv("BUILD_TAG"),
+		"Branch":      firstOf(getenv, "CHANGE_BRANCH", "BRANCH_NAME"),
+		"Tag":         getenv("TAG_NAME"),
+		"Commit":      getenv("GIT_COMMIT"),
+		"WorkingDir":  getenv("WORKSPACE"),
+		"PullRequest": getenv("CHANGE_ID"),
+	}
+
+	if values["Branch"] == "" {
+		values["Branch"] = strings.TrimPrefix(getenv("GIT_BRANCH"), "origin/")
+	}
+
+	return values
+}
+
+func isTrue(key string) func(func(string) string) bool {
+	return func(getenv func(string) string) bool {
+		return strings.ToLower(getenv(key)) == "true"
+	}
+}
+
+func isSet(key string) func(func(string) string) bool {
+	return func(getenv func(string) string) bool {
+		return getenv(key) != ""
+	}
+}
+
+func firstOf(getenv func(string) string, keys ...string) string {
+	for _, key := range keys {
+		if value := getenv(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
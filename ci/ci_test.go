@@ -0,0 +1,130 @@
+package ci
+
+import (
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		env      map[string]string
+		name     string
+		expected map[string]string
+	}{
+		{
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_REPOSITORY": "owner/repo",
+				"GITHUB_RUN_NUMBER": "12",
+				"GITHUB_RUN_ID":     "3456",
+				"GITHUB_JOB":        "test",
+				"GITHUB_REF":        "refs/pull/7/merge",
+				"GITHUB_HEAD_REF":   "feature",
+				"RUNNER_OS":         "Linux",
+				"GITHUB_WORKSPACE":  "/home/runner/work/repo",
+			},
+			name: "github-actions",
+			expected: map[string]string{
+				"RepoSlug":    "owner/repo",
+				"BuildNumber": "12",
+				"BuildID":     "3456",
+				"JobNumber":   "test",
+				"Branch":      "feature",
+				"PullRequest": "7",
+				"OS":          "linux",
+				"WorkingDir":  "/home/runner/work/repo",
+			},
+		},
+		{
+			env: map[string]string{
+				"GITHUB_ACTIONS":     "true",
+				"GITHUB_JOB":         "test",
+				"GITHUB_RUN_ATTEMPT": "2",
+				"GITHUB_REF":         "refs/heads/master",
+			},
+			name: "github-actions",
+			expected: map[string]string{
+				"JobNumber": "test.2",
+				"Branch":    "master",
+			},
+		},
+		{
+			env: map[string]string{
+				"GITLAB_CI":        "true",
+				"CI_PROJECT_PATH":  "group/project",
+				"CI_PIPELINE_IID":  "8",
+				"CI_JOB_ID":        "99",
+				"CI_COMMIT_TAG":    "v1.0.0",
+				"CI_COMMIT_BRANCH": "",
+			},
+			name: "gitlab",
+			expected: map[string]string{
+				"RepoSlug":    "group/project",
+				"BuildNumber": "8",
+				"JobID":       "99",
+				"Tag":         "v1.0.0",
+			},
+		},
+		{
+			env: map[string]string{
+				"CIRCLECI":                 "true",
+				"CIRCLE_PROJECT_USERNAME":  "owner",
+				"CIRCLE_PROJECT_REPONAME":  "repo",
+				"CIRCLE_PULL_REQUEST":      "https://github.com/owner/repo/pull/42",
+				"CIRCLE_WORKING_DIRECTORY": "~/project",
+				"HOME":                     "/home/circleci",
+			},
+			name: "circleci",
+			expected: map[string]string{
+				"RepoSlug":    "owner/repo",
+				"PullRequest": "42",
+				"WorkingDir":  "/home/circleci/project",
+			},
+		},
+		{
+			env: map[string]string{
+				"JENKINS_URL":  "https://jenkins.example.com/",
+				"BUILD_NUMBER": "5",
+				"GIT_BRANCH":   "origin/master",
+			},
+			name: "jenkins",
+			expected: map[string]string{
+				"BuildNumber": "5",
+				"JobNumber":   "5",
+				"Branch":      "master",
+			},
+		},
+		{
+			env: map[string]string{
+				"BUILDKITE":                   "true",
+				"BUILDKITE_ORGANIZATION_SLUG": "org",
+				"BUILDKITE_PIPELINE_SLUG":     "pipeline",
+				"BUILDKITE_PULL_REQUEST":      "false",
+			},
+			name: "buildkite",
+			expected: map[string]string{
+				"RepoSlug":    "org/pipeline",
+				"PullRequest": "false",
+			},
+		},
+	} {
+		c := detect(func(key string) string { return tc.env[key] })
+		if c == nil || c.Name != tc.name {
+			t.Fatalf("%v != %v", c, tc.name)
+		}
+
+		for name, value := range tc.expected {
+			if c.Value(name) != value {
+				t.Fatalf("%s %s: %q != %q", tc.name, name, c.Value(name), value)
+			}
+		}
+	}
+
+	if c := detect(func(string) string { return "" }); c != nil {
+		t.Fatalf("detected %v without any CI env", c.Name)
+	}
+
+	var c *CI
+	if c.Value("RepoSlug") != "" {
+		t.Fatalf("nil CI has a value")
+	}
+}
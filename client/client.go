@@ -69,6 +69,20 @@ func (c *Client) PutArtifact(a *artifact.Artifact) error {
 	req.Header.Set("Artifacts-Dest", a.FullDest())
 	req.Header.Set("Artifacts-Job-Number", a.JobNumber)
 	req.Header.Set("Artifacts-Size", fmt.Sprintf("%d", size))
+	req.Header.Set("Artifacts-Permissions", string(a.Perm))
+	req.Header.Set("Content-Type", a.ContentType())
+
+	if a.CacheControl != "" {
+		req.Header.Set("Cache-Control", a.CacheControl)
+	}
+
+	if a.ContentDisposition != "" {
+		req.Header.Set("Content-Disposition", a.ContentDisposition)
+	}
+
+	if a.SymlinkTarget != "" {
+		req.Header.Set("Artifacts-Symlink-Target", a.SymlinkTarget)
+	}
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
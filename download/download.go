@@ -0,0 +1,263 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/cas"
+	"github.com/travis-ci/artifacts/encryption"
+	"github.com/travis-ci/artifacts/remote"
+	"github.com/travis-ci/artifacts/signing"
+)
+
+type downloader struct {
+	Opts *Options
+
+	bucket    *s3.Bucket
+	verifier  *signing.Verifier
+	decrypter *encryption.Decrypter
+	log       *logrus.Logger
+}
+
+// Download does the deed, in reverse!
+func Download(opts *Options, log *logrus.Logger) error {
+	bucket, err := opts.Bucket()
+	if err != nil {
+		return err
+	}
+
+	d := newDownloader(opts, bucket, log)
+	if opts.VerifyKey != "" {
+		d.verifier, err = signing.NewVerifier(opts.VerifyKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return d.Download()
+}
+
+func newDownloader(opts *Options, bucket *s3.Bucket, log *logrus.Logger) *downloader {
+	return &downloader{
+		Opts:      opts,
+		bucket:    bucket,
+		decrypter: encryption.NewDecrypter(opts.EncryptionPassphrase),
+		log:       log,
+	}
+}
+
+func (d *downloader) Download() error {
+	failed := 0
+
+	for _, p := range d.Opts.Paths {
+		keys, err := d.keys(p)
+		if err != nil {
+			return err
+		}
+
+		for key, rel := range keys {
+			local, err := localPath(d.Opts.Dest, rel)
+			if err == nil {
+				err = d.fetch(key, local)
+			}
+
+			if err != nil {
+				d.log.WithFields(logrus.Fields{
+					"key": key,
+					"err": err,
+				}).Error(fmt.Sprintf("failed to download: %s", key))
+				failed++
+				continue
+			}
+
+			d.log.WithFields(logrus.Fields{
+				"key":  key,
+				"dest": local,
+			}).Info(fmt.Sprintf("downloaded: %s", key))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d artifacts failed to download", failed)
+	}
+
+	return nil
+}
+
+// keys maps the keys to download for a path to where they go relative
+// to the destination directory
+func (d *downloader) keys(p string) (map[string]string, error) {
+	p = strings.TrimLeft(p, "/")
+	if p != "" && !strings.HasSuffix(p, "/") {
+		return map[string]string{p: path.Base(p)}, nil
+	}
+
+	listed, err := remote.Keys(d.bucket, p)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]string{}
+	for _, k := range listed {
+		if d.verifier != nil && strings.HasSuffix(k.Key, signing.Ext) {
+			// checked along with the artifact instead
+			continue
+		}
+		keys[k.Key] = strings.TrimPrefix(k.Key, p)
+	}
+
+	return keys, nil
+}
+
+// open gets the key's body, following a pointer to a content-addressed
+// blob if the key is one and decrypting it if it is encrypted.  A blob
+// comes with a verifier, which can only tell if the blob is good once
+// the body has been read to the end.
+func (d *downloader) open(key string) (io.Reader, io.Closer, *cas.Verifier, error) {
+	resp, err := d.bucket.GetResponse(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var body io.Reader = resp.Body
+	var verifier *cas.Verifier
+
+	if blobKey, sum := cas.Resolve(resp.Header); blobKey != "" {
+		resp.Body.Close()
+		d.log.WithFields(logrus.Fields{
+			"key":  key,
+			"blob": blobKey,
+		}).Debug("resolving pointer to blob")
+
+		resp, err = d.bucket.GetResponse(blobKey)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		verifier = cas.NewVerifier(resp.Body, sum)
+		body = verifier
+	}
+
+	if encryption.IsEncrypted(resp.Header) {
+		body, err = d.decrypter.Decrypt(body, resp.Header)
+		if err != nil {
+			resp.Body.Close()
+			return nil, nil, nil, err
+		}
+	}
+
+	return body, resp.Body, verifier, nil
+}
+
+// fetch downloads the key to the local path, checking the blob it
+// points to and its signature if there is a verifier
+func (d *downloader) fetch(key, local string) error {
+	body, closer, verifier, err := d.open(key)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	var signature []byte
+	digest := signing.NewHash()
+	if d.verifier != nil {
+		signature, err = d.signature(key)
+		if err != nil {
+			return fmt.Errorf("failed to get signature: %v", err)
+		}
+		body = io.TeeReader(body, digest)
+	}
+
+	return writeFile(local, body, func() error {
+		if verifier != nil {
+			if err := verifier.Verify(); err != nil {
+				return err
+			}
+		}
+
+		if d.verifier != nil {
+			comment, err := d.verifier.VerifyDigest(digest.Sum(nil), signature)
+			if err != nil {
+				return err
+			}
+			d.log.WithFields(logrus.Fields{
+				"key":     key,
+				"comment": comment,
+			}).Debug("verified signature")
+		}
+		return nil
+	})
+}
+
+// signature gets the key's detached signature, which is a pointer to a
+// blob or encrypted too if the key is
+func (d *downloader) signature(key string) ([]byte, error) {
+	body, closer, verifier, err := d.open(key + signing.Ext)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	signature, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if verifier != nil {
+		if err := verifier.Verify(); err != nil {
+			return nil, err
+		}
+	}
+
+	return signature, nil
+}
+
+// writeFile writes the body to a temporary file next to filename, which
+// is only moved into place if check passes
+func writeFile(filename string, body io.Reader, check func() error) error {
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".download")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tmp, body)
+	if err == nil {
+		err = check()
+	}
+
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// localPath joins rel onto dest, refusing to go outside of dest
+func localPath(dest, rel string) (string, error) {
+	local := filepath.Join(dest, filepath.FromSlash(rel))
+
+	relLocal, err := filepath.Rel(dest, local)
+	if err != nil || rel == "" || relLocal == "." || strings.HasPrefix(relLocal, "..") {
+		return "", fmt.Errorf("refusing to download %q to %q", rel, local)
+	}
+
+	return local, nil
+}
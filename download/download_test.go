@@ -0,0 +1,182 @@
+package download
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/cas"
+	"github.com/travis-ci/artifacts/remote"
+	"github.com/travis-ci/artifacts/signing"
+)
+
+func TestLocalPath(t *testing.T) {
+	for rel, expected := range map[string]string{
+		"foo.txt":           "foo.txt",
+		"reports/junit.xml": filepath.Join("reports", "junit.xml"),
+		"a/../b":            "b",
+		"../escape":         "",
+		"":                  "",
+	} {
+		local, err := localPath(".", rel)
+		if expected == "" {
+			if err == nil {
+				t.Fatalf("%q was allowed to download to %q", rel, local)
+			}
+			continue
+		}
+
+		if err != nil || local != expected {
+			t.Fatalf("%v != %v (err %v)", local, expected, err)
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts-download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "sub", "ok.txt")
+	err = writeFile(filename, bytes.NewBufferString("ok"), func() error { return nil })
+	if err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil || string(b) != "ok" {
+		t.Fatalf("%q != ok (err %v)", string(b), err)
+	}
+
+	filename = filepath.Join(dir, "bad.txt")
+	err = writeFile(filename, bytes.NewBufferString("bad"), func() error { return fmt.Errorf("nope") })
+	if err == nil {
+		t.Fatalf("failed check did not fail the write")
+	}
+
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatalf("file written despite failed check: %v", err)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	opts := &Options{Options: remote.Options{S3Region: "us-east-1"}}
+	if opts.Validate() == nil {
+		t.Fatalf("options without bucket were valid")
+	}
+
+	opts.BucketName = "bucket"
+	if opts.Validate() == nil {
+		t.Fatalf("options without paths were valid")
+	}
+
+	opts.Paths = []string{"artifacts/1/"}
+	if opts.Validate() != nil {
+		t.Fatalf("valid options were invalid")
+	}
+
+	opts.VerifyKey = "does-not-exist.pub"
+	if opts.Validate() == nil {
+		t.Fatalf("options with invalid verify key were valid")
+	}
+}
+
+func TestFetchVerifiesCASSignature(t *testing.T) {
+	pub, sk, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyID := []byte("artifact")
+	secret := append([]byte("Ed\x00\x00B2"), make([]byte, 32+8+8)...)
+	secret = append(append(append(secret, keyID...), sk...), make([]byte, 32)...)
+	public := append(append([]byte("Ed"), keyID...), pub...)
+
+	signer, err := signing.NewSigner(base64.StdEncoding.EncodeToString(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("hello, blob")
+	signature, err := signer.Sign(bytes.NewReader(content), "1/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both the artifact and its signature are pointers to blobs
+	objects := map[string][]byte{}
+	pointers := map[string]map[string][]string{}
+	for key, body := range map[string][]byte{
+		"1/hello.txt":               content,
+		"1/hello.txt" + signing.Ext: signature,
+	} {
+		sum := sha256.Sum256(body)
+		blobKey := cas.BlobKey("cas", hex.EncodeToString(sum[:]))
+		objects[blobKey] = body
+		pointers[key] = cas.PointerHeaders(blobKey, hex.EncodeToString(sum[:]))
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+		if headers, ok := pointers[key]; ok {
+			for k, v := range headers {
+				w.Header()[http.CanonicalHeaderKey(k)] = v
+			}
+			return
+		}
+		if body, ok := objects[key]; ok {
+			w.Write(body)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "artifacts-download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := s3.New(aws.Auth{AccessKey: "whatever", SecretKey: "whatever"},
+		aws.Region{Name: "faux-region-9000", S3Endpoint: ts.URL}).Bucket("bucket")
+
+	log := logrus.New()
+	log.Level = logrus.PanicLevel
+
+	d := newDownloader(NewOptions(), b, log)
+	d.verifier, err = signing.NewVerifier(base64.StdEncoding.EncodeToString(public))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := filepath.Join(dir, "hello.txt")
+	err = d.fetch("1/hello.txt", local)
+	if err != nil {
+		t.Fatalf("failed to fetch: %v", err)
+	}
+
+	written, err := ioutil.ReadFile(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(written, content) {
+		t.Fatalf("%q != %q", written, content)
+	}
+}
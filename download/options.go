@@ -0,0 +1,101 @@
+package download
+
+import (
+	"fmt"
+
+	"github.com/codegangsta/cli"
+	"github.com/travis-ci/artifacts/env"
+	"github.com/travis-ci/artifacts/remote"
+	"github.com/travis-ci/artifacts/signing"
+)
+
+const (
+	// CommandDescription is the string used to describe the
+	// "download" command in the command line help system
+	CommandDescription = `
+Download artifacts from S3.  Each argument is either a key, which is saved
+under its base name, or a prefix ending in "/", under which every key is saved
+relative to the prefix.  Pointers to content-addressed blobs are resolved and
+the blob's checksum verified.  With a verify key, each artifact's detached
+signature is checked before it is saved, and the signatures themselves are not
+saved.  Encrypted artifacts are decrypted with the encryption passphrase.
+`
+)
+
+// Options is used in the call to Download
+type Options struct {
+	remote.Options
+
+	Dest      string
+	Paths     []string
+	VerifyKey string
+
+	EncryptionPassphrase string
+}
+
+// NewOptions makes some *Options with defaults!
+func NewOptions() *Options {
+	verifyKey, _ := env.CascadeMatch([]string{"ARTIFACTS_VERIFY_KEY"}, "")
+	passphrase, _ := env.CascadeMatch([]string{"ARTIFACTS_ENCRYPTION_PASSPHRASE"}, "")
+	return &Options{
+		Options:   remote.NewOptions(),
+		Dest:      ".",
+		Paths:     []string{},
+		VerifyKey: verifyKey,
+
+		EncryptionPassphrase: passphrase,
+	}
+}
+
+// Flags returns the command line flags of the download command
+func Flags() []cli.Flag {
+	return append(remote.Flags(),
+		cli.StringFlag{Name: "dest, d", Usage: "local directory to download to (default \".\")"},
+		cli.StringFlag{Name: "verify-key", Usage: "minisign public key file, or the key itself, to verify signatures with"},
+		cli.StringFlag{Name: "encryption-passphrase", Usage: "passphrase to decrypt encrypted artifacts with"})
+}
+
+// UpdateFromCLI overlays a *cli.Context onto the options
+func (opts *Options) UpdateFromCLI(c *cli.Context) {
+	opts.Options.UpdateFromCLI(c)
+
+	if value := c.String("dest"); value != "" {
+		opts.Dest = value
+	}
+
+	if value := c.String("verify-key"); value != "" {
+		opts.VerifyKey = value
+	}
+
+	if value := c.String("encryption-passphrase"); value != "" {
+		opts.EncryptionPassphrase = value
+	}
+
+	opts.Paths = append(opts.Paths, c.Args()...)
+}
+
+// Secrets returns the credentials and passphrase, for redacting them
+// from the logs
+func (opts *Options) Secrets() []string {
+	return append(opts.Options.Secrets(), opts.EncryptionPassphrase)
+}
+
+// Validate checks for validity!
+func (opts *Options) Validate() error {
+	err := opts.Options.Validate()
+	if err != nil {
+		return err
+	}
+
+	if len(opts.Paths) == 0 {
+		return fmt.Errorf("no paths given")
+	}
+
+	if opts.VerifyKey != "" {
+		if _, err := signing.NewVerifier(opts.VerifyKey); err != nil {
+			return fmt.Errorf("invalid verify key: %v", err)
+		}
+	}
+
+	return nil
+}
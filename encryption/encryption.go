@@ -0,0 +1,283 @@
+// Package encryption encrypts artifacts on the client, so that they are
+// only readable by whoever has the passphrase, not by whoever has access
+// to the bucket.
+//
+// Each artifact gets a random data key, with which it is encrypted with
+// AES-256-GCM in chunks, so that it can be streamed.  The data key is
+// wrapped with a key derived from the passphrase, and stored in the
+// object's metadata along with what is needed to derive it again.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const (
+	// Scheme names the encryption in the object metadata
+	Scheme = "aes-256-gcm-stream-v1"
+	// KDF names how the key wrapping the data key is derived
+	KDF = "pbkdf2-sha256"
+
+	// SchemeHeader is the metadata header holding the scheme of an
+	// encrypted object
+	SchemeHeader = "x-amz-meta-artifacts-encryption"
+	// KDFHeader is the metadata header holding the KDF
+	KDFHeader = "x-amz-meta-artifacts-encryption-kdf"
+	// SaltHeader is the metadata header holding the base64 KDF salt
+	SaltHeader = "x-amz-meta-artifacts-encryption-salt"
+	// IterationsHeader is the metadata header holding the KDF iterations
+	IterationsHeader = "x-amz-meta-artifacts-encryption-iterations"
+	// KeyHeader is the metadata header holding the base64 nonce and
+	// wrapped data key
+	KeyHeader = "x-amz-meta-artifacts-encryption-key"
+
+	// ChunkSize is how much plaintext is sealed at a time.  Every chunk
+	// but the last is full, and the last one never is, so there is
+	// always a last chunk, even if it is empty.
+	ChunkSize = 64 * 1024
+
+	iterations = 600000
+	keySize    = 32
+	saltSize   = 16
+	tagSize    = 16
+)
+
+// Key encrypts artifacts with data keys wrapped by a passphrase
+type Key struct {
+	kek        cipher.AEAD
+	salt       []byte
+	iterations int
+}
+
+// NewKey derives a key from the passphrase with a new random salt, which
+// is slow on purpose, so it is done once per run
+func NewKey(passphrase string) (*Key, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	kek, err := deriveKEK(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{kek: kek, salt: salt, iterations: iterations}, nil
+}
+
+// Encrypt wraps r to encrypt what is read from it with a new data key,
+// returning the metadata headers the object needs to be decrypted
+func (k *Key) Encrypt(r io.Reader) (io.Reader, map[string][]string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, k.kek.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wrapped := k.kek.Seal(nonce, nonce, dataKey, []byte(Scheme))
+	headers := map[string][]string{
+		SchemeHeader:     []string{Scheme},
+		KDFHeader:        []string{KDF},
+		SaltHeader:       []string{base64.StdEncoding.EncodeToString(k.salt)},
+		IterationsHeader: []string{strconv.Itoa(k.iterations)},
+		KeyHeader:        []string{base64.StdEncoding.EncodeToString(wrapped)},
+	}
+
+	return &chunkReader{r: r, aead: aead, seal: true}, headers, nil
+}
+
+// EncryptedSize is the size of size bytes of plaintext once encrypted
+func EncryptedSize(size uint64) uint64 {
+	return size + (size/ChunkSize+1)*tagSize
+}
+
+// IsEncrypted tells if an object's headers are those of an encrypted
+// object
+func IsEncrypted(header http.Header) bool {
+	return header.Get(SchemeHeader) != ""
+}
+
+// Decrypter decrypts objects with a passphrase, remembering the keys
+// derived from it for each salt
+type Decrypter struct {
+	sync.Mutex
+
+	passphrase string
+	keks       map[string]cipher.AEAD
+}
+
+// NewDecrypter makes a *Decrypter for the passphrase
+func NewDecrypter(passphrase string) *Decrypter {
+	return &Decrypter{passphrase: passphrase, keks: map[string]cipher.AEAD{}}
+}
+
+// Decrypt wraps r to decrypt the object with the headers.  Reading
+// returns an error if the object was tampered with or cut short.
+func (d *Decrypter) Decrypt(r io.Reader, header http.Header) (io.Reader, error) {
+	if scheme := header.Get(SchemeHeader); scheme != Scheme {
+		return nil, fmt.Errorf("unsupported encryption scheme %q", scheme)
+	}
+
+	if kdf := header.Get(KDFHeader); kdf != KDF {
+		return nil, fmt.Errorf("unsupported key derivation %q", kdf)
+	}
+
+	if d.passphrase == "" {
+		return nil, fmt.Errorf("object is encrypted, but no passphrase was given")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(header.Get(SaltHeader))
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("invalid encryption salt")
+	}
+
+	iter, err := strconv.Atoi(header.Get(IterationsHeader))
+	if err != nil || iter <= 0 {
+		return nil, fmt.Errorf("invalid encryption iterations")
+	}
+
+	kek, err := d.kek(salt, iter)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(header.Get(KeyHeader))
+	if err != nil || len(wrapped) < kek.NonceSize() {
+		return nil, fmt.Errorf("invalid encryption key")
+	}
+
+	dataKey, err := kek.Open(nil, wrapped[:kek.NonceSize()], wrapped[kek.NonceSize():], []byte(Scheme))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key, wrong passphrase?")
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chunkReader{r: r, aead: aead}, nil
+}
+
+func (d *Decrypter) kek(salt []byte, iter int) (cipher.AEAD, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	cacheKey := fmt.Sprintf("%x:%d", salt, iter)
+	if kek, ok := d.keks[cacheKey]; ok {
+		return kek, nil
+	}
+
+	kek, err := deriveKEK(d.passphrase, salt, iter)
+	if err != nil {
+		return nil, err
+	}
+
+	d.keks[cacheKey] = kek
+	return kek, nil
+}
+
+func deriveKEK(passphrase string, salt []byte, iter int) (cipher.AEAD, error) {
+	return newAEAD(pbkdf2Key([]byte(passphrase), salt, iter, keySize, sha256.New))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkReader seals or opens what is read through it a chunk at a time.
+// Each chunk's nonce is its number, with the last byte set for the last
+// chunk, so chunks can't be reordered, dropped or cut off at the end.
+type chunkReader struct {
+	r    io.Reader
+	aead cipher.AEAD
+	seal bool
+
+	n    uint64
+	buf  []byte
+	out  []byte
+	done bool
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for len(cr.out) == 0 {
+		if cr.done {
+			return 0, io.EOF
+		}
+
+		if err := cr.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, cr.out)
+	cr.out = cr.out[n:]
+	return n, nil
+}
+
+func (cr *chunkReader) next() error {
+	size := ChunkSize
+	if !cr.seal {
+		size += cr.aead.Overhead()
+	}
+
+	if cr.buf == nil {
+		cr.buf = make([]byte, size)
+	}
+
+	n, err := io.ReadFull(cr.r, cr.buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+
+	last := n < size
+	if !cr.seal && last && n < cr.aead.Overhead() {
+		return fmt.Errorf("encrypted object is truncated")
+	}
+
+	nonce := make([]byte, cr.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[cr.aead.NonceSize()-9:], cr.n)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	cr.n++
+
+	if cr.seal {
+		cr.out = cr.aead.Seal(cr.out[:0], nonce, cr.buf[:n], nil)
+	} else {
+		cr.out, err = cr.aead.Open(cr.out[:0], nonce, cr.buf[:n], nil)
+		if err != nil {
+			return fmt.Errorf("encrypted object is corrupt or truncated")
+		}
+	}
+
+	cr.done = last
+	return nil
+}
@@ -0,0 +1,116 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func encrypt(t *testing.T, key *Key, plaintext []byte) ([]byte, http.Header) {
+	r, headers, err := key.Encrypt(bytes.NewReader(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{}
+	for k, v := range headers {
+		header[http.CanonicalHeaderKey(k)] = v
+	}
+
+	return ciphertext, header
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, err := NewKey("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecrypter("hunter2")
+
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3 * ChunkSize} {
+		plaintext := bytes.Repeat([]byte("x"), size)
+		ciphertext, header := encrypt(t, key, plaintext)
+
+		if uint64(len(ciphertext)) != EncryptedSize(uint64(size)) {
+			t.Fatalf("%v: %v != %v", size, len(ciphertext), EncryptedSize(uint64(size)))
+		}
+
+		if !IsEncrypted(header) {
+			t.Fatalf("%v: headers are not an encrypted object's: %v", size, header)
+		}
+
+		r, err := d.Decrypt(bytes.NewReader(ciphertext), header)
+		if err != nil {
+			t.Fatalf("%v: failed to decrypt: %v", size, err)
+		}
+
+		decrypted, err := ioutil.ReadAll(r)
+		if err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("%v: decrypted %v bytes (err %v)", size, len(decrypted), err)
+		}
+
+		if size < ChunkSize {
+			continue
+		}
+
+		// cut off at a chunk boundary, as if the last chunk was lost
+		r, _ = d.Decrypt(bytes.NewReader(ciphertext[:ChunkSize+tagSize]), header)
+		if _, err := ioutil.ReadAll(r); err == nil {
+			t.Fatalf("%v: truncated object decrypted", size)
+		}
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	key, err := NewKey("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, header := encrypt(t, key, []byte("secret dump"))
+	ciphertext[0] ^= 1
+
+	r, err := NewDecrypter("hunter2").Decrypt(bytes.NewReader(ciphertext), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Fatalf("tampered object decrypted")
+	}
+
+	if _, err := NewDecrypter("hunter3").Decrypt(bytes.NewReader(ciphertext), header); err == nil {
+		t.Fatalf("data key unwrapped with the wrong passphrase")
+	}
+
+	if _, err := NewDecrypter("").Decrypt(bytes.NewReader(ciphertext), header); err == nil {
+		t.Fatalf("encrypted object decrypted without a passphrase")
+	}
+}
+
+func TestPBKDF2Key(t *testing.T) {
+	for _, tc := range []struct {
+		password, salt string
+		iter, keyLen   int
+		expected       string
+	}{
+		{"password", "salt", 1, 32, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 4096, 32, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 40,
+			"348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+	} {
+		key := hex.EncodeToString(pbkdf2Key([]byte(tc.password), []byte(tc.salt), tc.iter, tc.keyLen, sha256.New))
+		if key != tc.expected {
+			t.Fatalf("%s/%s/%d: %v != %v", tc.password, tc.salt, tc.iter, key, tc.expected)
+		}
+	}
+}
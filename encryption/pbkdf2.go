@@ -0,0 +1,43 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+)
+
+// pbkdf2Key derives a key as in RFC 8018, which crypto/pbkdf2 only
+// provides from Go 1.24 on
+func pbkdf2Key(password, salt []byte, iter, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	size := prf.Size()
+	blocks := (keyLen + size - 1) / size
+
+	key := make([]byte, 0, blocks*size)
+	counter := make([]byte, 4)
+	u := make([]byte, size)
+	t := make([]byte, size)
+
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter, uint32(block))
+
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter)
+		u = prf.Sum(u[:0])
+		copy(t, u)
+
+		for i := 1; i < iter; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
@@ -53,6 +53,21 @@ func Uint(key string, dflt uint64) uint64 {
 	return uintVal
 }
 
+// Bool returns a bool from the env
+func Bool(key string, dflt bool) bool {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return dflt
+	}
+
+	boolVal, err := strconv.ParseBool(value)
+	if err != nil {
+		return dflt
+	}
+
+	return boolVal
+}
+
 func expandSlice(vars []string) []string {
 	expanded := []string{}
 	for _, s := range vars {
@@ -52,6 +52,22 @@ func TestUint(t *testing.T) {
 	}
 }
 
+func TestBool(t *testing.T) {
+	for _, c := range []struct {
+		actual   bool
+		expected bool
+	}{
+		{Bool("FOO", false), true},
+		{Bool("BAR", true), true},
+		{Bool("BAZ", false), false},
+		{Bool("NOPE", false), false},
+	} {
+		if c.expected != c.actual {
+			t.Fatalf("%v != %v", c.expected, c.actual)
+		}
+	}
+}
+
 func TestExpandSlice(t *testing.T) {
 	for _, c := range []sliceCase{
 		sliceCase{
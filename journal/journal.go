@@ -0,0 +1,302 @@
+package journal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	version = 1
+
+	// saveInterval is how often completions are written, as writing the
+	// whole journal for each one gets slow with lots of artifacts
+	saveInterval = 5 * time.Second
+)
+
+// Entry records an artifact uploaded to a destination
+type Entry struct {
+	Provider string `json:"provider"`
+	Dest     string `json:"dest"`
+	Source   string `json:"source"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mtime"`
+	Checksum string `json:"checksum"`
+}
+
+// Multipart records an in-progress multipart upload
+type Multipart struct {
+	Provider string `json:"provider"`
+	Dest     string `json:"dest"`
+	Source   string `json:"source"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mtime"`
+	UploadID string `json:"upload_id"`
+	PartSize int64  `json:"part_size"`
+}
+
+// Journal is a file recording which artifacts have been uploaded and
+// which multipart uploads are in progress, so that a later run can skip
+// or resume them.  A nil *Journal is valid and records nothing.
+type Journal struct {
+	sync.Mutex
+
+	Filename string `json:"-"`
+
+	Version    int                   `json:"version"`
+	Completed  map[string]*Entry     `json:"completed"`
+	Multiparts map[string]*Multipart `json:"multipart"`
+
+	dirty    bool
+	lastSave time.Time
+}
+
+// Open loads the journal file, or starts a new journal if it does not
+// exist yet
+func Open(filename string) (*Journal, error) {
+	j := &Journal{
+		Filename:   filename,
+		Version:    version,
+		Completed:  map[string]*Entry{},
+		Multiparts: map[string]*Multipart{},
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, j)
+	if err != nil {
+		return nil, err
+	}
+
+	if j.Completed == nil {
+		j.Completed = map[string]*Entry{}
+	}
+
+	if j.Multiparts == nil {
+		j.Multiparts = map[string]*Multipart{}
+	}
+
+	return j, nil
+}
+
+// IsComplete tells if source was already uploaded to dest by provider,
+// comparing size and mtime before falling back to the checksum
+func (j *Journal) IsComplete(provider, dest, source string) bool {
+	if j == nil {
+		return false
+	}
+
+	j.Lock()
+	e, ok := j.Completed[key(provider, dest)]
+	j.Unlock()
+
+	if !ok || e.Source != source {
+		return false
+	}
+
+	fi, err := os.Stat(source)
+	if err != nil || fi.Size() != e.Size {
+		return false
+	}
+
+	if fi.ModTime().UnixNano() == e.ModTime {
+		return true
+	}
+
+	checksum, err := Checksum(source)
+	return err == nil && checksum == e.Checksum
+}
+
+// Complete records that source was uploaded to dest by provider, and
+// forgets any multipart upload for it.  The checksum is that of source
+// if it was hashed while uploading, or "" to hash it here.  Completions
+// are only written every few seconds, so Save must be called once done.
+func (j *Journal) Complete(provider, dest, source, checksum string) error {
+	if j == nil {
+		return nil
+	}
+
+	fi, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	if checksum == "" {
+		checksum, err = Checksum(source)
+		if err != nil {
+			return err
+		}
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	k := key(provider, dest)
+	j.Completed[k] = &Entry{
+		Provider: provider,
+		Dest:     dest,
+		Source:   source,
+		Size:     fi.Size(),
+		ModTime:  fi.ModTime().UnixNano(),
+		Checksum: checksum,
+	}
+	delete(j.Multiparts, k)
+	j.dirty = true
+
+	if time.Since(j.lastSave) < saveInterval {
+		return nil
+	}
+	return j.save()
+}
+
+// Save writes whatever was recorded since the journal was last written
+func (j *Journal) Save() error {
+	if j == nil {
+		return nil
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	if !j.dirty {
+		return nil
+	}
+	return j.save()
+}
+
+// Multipart returns the in-progress multipart upload of source to dest
+// by provider, if any, as long as source has not changed since
+func (j *Journal) Multipart(provider, dest, source string) *Multipart {
+	if j == nil {
+		return nil
+	}
+
+	j.Lock()
+	m, ok := j.Multiparts[key(provider, dest)]
+	j.Unlock()
+
+	if !ok || m.Source != source {
+		return nil
+	}
+
+	fi, err := os.Stat(source)
+	if err != nil || fi.Size() != m.Size || fi.ModTime().UnixNano() != m.ModTime {
+		return nil
+	}
+
+	return m
+}
+
+// StartMultipart records a multipart upload of source to dest by
+// provider
+func (j *Journal) StartMultipart(provider, dest, source, uploadID string, partSize int64) error {
+	if j == nil {
+		return nil
+	}
+
+	fi, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	j.Multiparts[key(provider, dest)] = &Multipart{
+		Provider: provider,
+		Dest:     dest,
+		Source:   source,
+		Size:     fi.Size(),
+		ModTime:  fi.ModTime().UnixNano(),
+		UploadID: uploadID,
+		PartSize: partSize,
+	}
+
+	return j.save()
+}
+
+// Remove deletes the journal file, e.g. once everything is uploaded
+func (j *Journal) Remove() error {
+	if j == nil {
+		return nil
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	j.dirty = false
+	err := os.Remove(j.Filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// save writes the journal atomically; the lock must be held
+func (j *Journal) save() error {
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(j.Filename), ".journal")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(b)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), j.Filename)
+	if err != nil {
+		return err
+	}
+
+	j.dirty = false
+	j.lastSave = time.Now()
+	return nil
+}
+
+// Checksum returns the hex-encoded SHA-256 of the file
+func Checksum(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func key(provider, dest string) string {
+	return provider + ":" + dest
+}
@@ -0,0 +1,179 @@
+package journal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, dir, content string) string {
+	filename := filepath.Join(dir, "artifact.txt")
+	err := ioutil.WriteFile(filename, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestJournalComplete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := writeTestFile(t, dir, "hello")
+	filename := filepath.Join(dir, "journal.json")
+
+	j, err := Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open new journal: %v", err)
+	}
+
+	if j.IsComplete("s3", "artifacts/1/artifact.txt", source) {
+		t.Fatalf("empty journal has a completed artifact")
+	}
+
+	err = j.Complete("s3", "artifacts/1/artifact.txt", source, "")
+	if err != nil {
+		t.Fatalf("failed to complete artifact: %v", err)
+	}
+
+	j, err = Open(filename)
+	if err != nil {
+		t.Fatalf("failed to reopen journal: %v", err)
+	}
+
+	if !j.IsComplete("s3", "artifacts/1/artifact.txt", source) {
+		t.Fatalf("completed artifact was not recorded")
+	}
+
+	if j.IsComplete("artifacts", "artifacts/1/artifact.txt", source) {
+		t.Fatalf("artifact completed for the wrong provider")
+	}
+
+	// same content with a new mtime, as after a fresh checkout
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(source, later, later)
+	if !j.IsComplete("s3", "artifacts/1/artifact.txt", source) {
+		t.Fatalf("touched artifact was not matched by checksum")
+	}
+
+	writeTestFile(t, dir, "world")
+	os.Chtimes(source, later, later)
+	if j.IsComplete("s3", "artifacts/1/artifact.txt", source) {
+		t.Fatalf("changed artifact was still complete")
+	}
+
+	err = j.Remove()
+	if err != nil {
+		t.Fatalf("failed to remove journal: %v", err)
+	}
+
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatalf("journal file still exists: %v", err)
+	}
+}
+
+func TestJournalSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := writeTestFile(t, dir, "hello")
+	filename := filepath.Join(dir, "journal.json")
+
+	j, err := Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open new journal: %v", err)
+	}
+
+	for _, dest := range []string{"1/artifact.txt", "2/artifact.txt"} {
+		err = j.Complete("s3", dest, source, "streamed")
+		if err != nil {
+			t.Fatalf("failed to complete artifact: %v", err)
+		}
+	}
+
+	saved, err := Open(filename)
+	if err != nil {
+		t.Fatalf("failed to reopen journal: %v", err)
+	}
+
+	if len(saved.Completed) != 1 {
+		t.Fatalf("saved %v completions != 1", len(saved.Completed))
+	}
+
+	err = j.Save()
+	if err != nil {
+		t.Fatalf("failed to save journal: %v", err)
+	}
+
+	saved, err = Open(filename)
+	if err != nil {
+		t.Fatalf("failed to reopen journal: %v", err)
+	}
+
+	if len(saved.Completed) != 2 {
+		t.Fatalf("saved %v completions != 2", len(saved.Completed))
+	}
+
+	if saved.Completed[key("s3", "2/artifact.txt")].Checksum != "streamed" {
+		t.Fatalf("checksum %v != streamed", saved.Completed[key("s3", "2/artifact.txt")].Checksum)
+	}
+}
+
+func TestJournalMultipart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := writeTestFile(t, dir, "hello")
+
+	j, err := Open(filepath.Join(dir, "journal.json"))
+	if err != nil {
+		t.Fatalf("failed to open new journal: %v", err)
+	}
+
+	err = j.StartMultipart("s3", "big.bin", source, "upload-id", 5)
+	if err != nil {
+		t.Fatalf("failed to start multipart: %v", err)
+	}
+
+	m := j.Multipart("s3", "big.bin", source)
+	if m == nil || m.UploadID != "upload-id" {
+		t.Fatalf("unexpected multipart %v", m)
+	}
+
+	writeTestFile(t, dir, "hello, world")
+	if j.Multipart("s3", "big.bin", source) != nil {
+		t.Fatalf("multipart resumed for changed source")
+	}
+
+	err = j.Complete("s3", "big.bin", source, "")
+	if err != nil {
+		t.Fatalf("failed to complete artifact: %v", err)
+	}
+
+	if len(j.Multiparts) != 0 {
+		t.Fatalf("multipart not forgotten on completion")
+	}
+}
+
+func TestNilJournal(t *testing.T) {
+	var j *Journal
+
+	if j.IsComplete("s3", "foo", "foo") {
+		t.Fatalf("nil journal has a completed artifact")
+	}
+
+	if j.Complete("s3", "foo", "foo", "") != nil || j.Save() != nil || j.Remove() != nil {
+		t.Fatalf("nil journal returned an error")
+	}
+}
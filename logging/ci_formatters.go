@@ -0,0 +1,171 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// GroupStartField marks an entry as starting a collapsible group of
+	// log lines, named by the field's value, for the CI formatters
+	GroupStartField = "group_start"
+	// GroupEndField marks an entry as ending the group named by the
+	// field's value
+	GroupEndField = "group_end"
+)
+
+var (
+	githubDataEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+)
+
+// TravisFormatter folds groups with travis_fold markers
+type TravisFormatter struct {
+	Formatter logrus.Formatter
+}
+
+// Format creates a formatted entry
+func (f *TravisFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	start, end, entry := groupMarkers(entry)
+
+	b, err := inner(f.Formatter).Format(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if start != "" {
+		b = append([]byte(fmt.Sprintf("travis_fold:start:%s\r\033[0K", start)), b...)
+	}
+
+	if end != "" {
+		b = append(b, []byte(fmt.Sprintf("travis_fold:end:%s\r\033[0K", end))...)
+	}
+
+	return b, nil
+}
+
+// GitHubActionsFormatter groups with ::group:: workflow commands, and
+// turns errors and warnings into annotations
+type GitHubActionsFormatter struct {
+	Formatter logrus.Formatter
+}
+
+// Format creates a formatted entry
+func (f *GitHubActionsFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	start, end, entry := groupMarkers(entry)
+
+	var b []byte
+	if start != "" {
+		b = append(b, []byte(fmt.Sprintf("::group::%s\n", githubDataEscaper.Replace(entry.Message)))...)
+	}
+
+	switch entry.Level {
+	case logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel:
+		b = append(b, []byte(githubAnnotation("error", entry))...)
+	case logrus.WarnLevel:
+		b = append(b, []byte(githubAnnotation("warning", entry))...)
+	default:
+		formatted, err := inner(f.Formatter).Format(entry)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, formatted...)
+	}
+
+	if end != "" {
+		b = append(b, []byte("::endgroup::\n")...)
+	}
+
+	return b, nil
+}
+
+func githubAnnotation(command string, entry *logrus.Entry) string {
+	lines := []string{entry.Message}
+	for _, k := range sortedKeys(entry.Data) {
+		lines = append(lines, fmt.Sprintf("%s: %v", k, entry.Data[k]))
+	}
+
+	return fmt.Sprintf("::%s::%s\n", command, githubDataEscaper.Replace(strings.Join(lines, "\n")))
+}
+
+// GitLabFormatter makes collapsible sections of groups, and shows
+// errors in red
+type GitLabFormatter struct {
+	Formatter logrus.Formatter
+
+	now func() time.Time
+}
+
+// Format creates a formatted entry
+func (f *GitLabFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	start, end, entry := groupMarkers(entry)
+
+	now := time.Now
+	if f.now != nil {
+		now = f.now
+	}
+
+	formatted, err := inner(f.Formatter).Format(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Level <= logrus.ErrorLevel {
+		formatted = []byte(fmt.Sprintf("\033[31;1m%s\033[0m\n", strings.TrimRight(string(formatted), "\n")))
+	}
+
+	var b []byte
+	if start != "" {
+		b = append(b, []byte(fmt.Sprintf("\033[0Ksection_start:%d:%s[collapsed=true]\r\033[0K", now().Unix(), start))...)
+	}
+
+	b = append(b, formatted...)
+
+	if end != "" {
+		b = append(b, []byte(fmt.Sprintf("\033[0Ksection_end:%d:%s\r\033[0K\n", now().Unix(), end))...)
+	}
+
+	return b, nil
+}
+
+// groupMarkers returns the names of the groups the entry starts and
+// ends, and a copy of the entry without the marker fields
+func groupMarkers(entry *logrus.Entry) (string, string, *logrus.Entry) {
+	start, _ := entry.Data[GroupStartField].(string)
+	end, _ := entry.Data[GroupEndField].(string)
+	if start == "" && end == "" {
+		return "", "", entry
+	}
+
+	data := logrus.Fields{}
+	for k, v := range entry.Data {
+		if k != GroupStartField && k != GroupEndField {
+			data[k] = v
+		}
+	}
+
+	stripped := *entry
+	stripped.Data = data
+	return start, end, &stripped
+}
+
+func inner(f logrus.Formatter) logrus.Formatter {
+	if f == nil {
+		return &logrus.TextFormatter{}
+	}
+	return f
+}
+
+func sortedKeys(data logrus.Fields) []string {
+	keys := []string{}
+	for k := range data {
+		if k != "time" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func groupEntries() []*logrus.Entry {
+	log := logrus.New()
+	return []*logrus.Entry{
+		{
+			Logger:  log,
+			Level:   logrus.InfoLevel,
+			Message: "uploading artifacts",
+			Data:    logrus.Fields{GroupStartField: "artifacts_upload"},
+		},
+		{
+			Logger:  log,
+			Level:   logrus.ErrorLevel,
+			Message: "failed to upload: a.txt",
+			Data:    logrus.Fields{"err": "nope\nreally"},
+		},
+		{
+			Logger:  log,
+			Level:   logrus.InfoLevel,
+			Message: "done uploading",
+			Data:    logrus.Fields{GroupEndField: "artifacts_upload"},
+		},
+	}
+}
+
+func formatAll(t *testing.T, f logrus.Formatter) []string {
+	out := []string{}
+	for _, entry := range groupEntries() {
+		b, err := f.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, string(b))
+	}
+	return out
+}
+
+func TestTravisFormatter(t *testing.T) {
+	out := formatAll(t, &TravisFormatter{Formatter: &MultiLineFormatter{}})
+
+	if out[0] != "travis_fold:start:artifacts_upload\r\033[0KINFO: uploading artifacts\n\n" {
+		t.Fatalf("unexpected group start %q", out[0])
+	}
+
+	if !strings.HasSuffix(out[2], "travis_fold:end:artifacts_upload\r\033[0K") {
+		t.Fatalf("unexpected group end %q", out[2])
+	}
+
+	if strings.Contains(out[0], GroupStartField) {
+		t.Fatalf("group field was not stripped: %q", out[0])
+	}
+}
+
+func TestGitHubActionsFormatter(t *testing.T) {
+	out := formatAll(t, &GitHubActionsFormatter{Formatter: &MultiLineFormatter{}})
+
+	if !strings.HasPrefix(out[0], "::group::uploading artifacts\n") {
+		t.Fatalf("unexpected group start %q", out[0])
+	}
+
+	if out[1] != "::error::failed to upload: a.txt%0Aerr: nope%0Areally\n" {
+		t.Fatalf("unexpected annotation %q", out[1])
+	}
+
+	if !strings.HasSuffix(out[2], "::endgroup::\n") {
+		t.Fatalf("unexpected group end %q", out[2])
+	}
+}
+
+func TestGitLabFormatter(t *testing.T) {
+	now := time.Unix(1426856400, 0)
+	out := formatAll(t, &GitLabFormatter{
+		Formatter: &MultiLineFormatter{},
+		now:       func() time.Time { return now },
+	})
+
+	if !strings.HasPrefix(out[0], "\033[0Ksection_start:1426856400:artifacts_upload[collapsed=true]\r\033[0K") {
+		t.Fatalf("unexpected section start %q", out[0])
+	}
+
+	if !strings.HasSuffix(out[2], "\033[0Ksection_end:1426856400:artifacts_upload\r\033[0K\n") {
+		t.Fatalf("unexpected section end %q", out[2])
+	}
+}
@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/travis-ci/artifacts/ci"
+)
+
+// NewFormatter returns the formatter for a --log-format value, where
+// "auto" or "" picks the CI formatter for the CI detected, if any
+func NewFormatter(format string) logrus.Formatter {
+	if format == "" || format == "auto" {
+		format = "text"
+		if detected := ci.Detect(); detected != nil {
+			switch detected.Name {
+			case "travis", "gitlab":
+				format = detected.Name
+			case "github-actions":
+				format = "github"
+			}
+		}
+	}
+
+	switch format {
+	case "json":
+		return &logrus.JSONFormatter{}
+	case "multiline":
+		return &MultiLineFormatter{}
+	case "travis":
+		return &TravisFormatter{}
+	case "github":
+		return &GitHubActionsFormatter{}
+	case "gitlab":
+		return &GitLabFormatter{}
+	default:
+		return &logrus.TextFormatter{}
+	}
+}
@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// Redacted is what secrets are replaced with in log output
+	Redacted = "[REDACTED]"
+)
+
+var (
+	// signed URL parameters are redacted even when the secret behind
+	// them was never configured, e.g. for instance role credentials
+	signatureParams = regexp.MustCompile(
+		`(?i)((?:X-Amz-)?Signature|X-Amz-Security-Token|X-Amz-Credential)=[^&\s"']+`)
+
+	// session tokens are picked up by the AWS client from the env
+	// without ever going through the options
+	sessionTokenEnv = []string{"AWS_SESSION_TOKEN", "AWS_SECURITY_TOKEN"}
+)
+
+// RedactingFormatter wraps another formatter, replacing secrets in the
+// message and field values of each entry before it is formatted
+type RedactingFormatter struct {
+	sync.RWMutex
+
+	Formatter logrus.Formatter
+
+	secrets []string
+}
+
+// NewRedactingFormatter wraps f, redacting any session token in the env
+// from the start
+func NewRedactingFormatter(f logrus.Formatter) *RedactingFormatter {
+	rf := &RedactingFormatter{Formatter: f}
+	for _, name := range sessionTokenEnv {
+		rf.AddSecrets(os.Getenv(name))
+	}
+	return rf
+}
+
+// AddSecrets adds values to redact, ignoring blank ones
+func (rf *RedactingFormatter) AddSecrets(secrets ...string) {
+	rf.Lock()
+	defer rf.Unlock()
+
+	for _, secret := range secrets {
+		if strings.TrimSpace(secret) != "" {
+			rf.secrets = append(rf.secrets, secret)
+		}
+	}
+
+	// longest first, so that a secret containing another one is
+	// redacted whole
+	sort.Sort(byLength(rf.secrets))
+}
+
+// Unwrap returns the formatter behind any redaction, for telling what
+// the output will look like
+func Unwrap(f logrus.Formatter) logrus.Formatter {
+	for {
+		rf, ok := f.(*RedactingFormatter)
+		if !ok {
+			return f
+		}
+		f = rf.Formatter
+	}
+}
+
+// Redact replaces the secrets and URL signatures in s
+func (rf *RedactingFormatter) Redact(s string) string {
+	rf.RLock()
+	defer rf.RUnlock()
+
+	for _, secret := range rf.secrets {
+		s = strings.Replace(s, secret, Redacted, -1)
+	}
+
+	return signatureParams.ReplaceAllString(s, "${1}="+Redacted)
+}
+
+// Format redacts a copy of the entry and hands it to the wrapped
+// formatter.  Field values that are not strings are only replaced by
+// their redacted string form if they had something to redact.
+func (rf *RedactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	redacted := *entry
+	redacted.Message = rf.Redact(entry.Message)
+	redacted.Data = make(logrus.Fields, len(entry.Data))
+
+	for k, v := range entry.Data {
+		if s, ok := v.(string); ok {
+			redacted.Data[k] = rf.Redact(s)
+			continue
+		}
+
+		s := fmt.Sprintf("%v", v)
+		if r := rf.Redact(s); r != s {
+			redacted.Data[k] = r
+			continue
+		}
+
+		redacted.Data[k] = v
+	}
+
+	return rf.Formatter.Format(&redacted)
+}
+
+// AddSecrets adds secrets to redact from the logger's output, if its
+// formatter redacts at all
+func AddSecrets(log *logrus.Logger, secrets ...string) {
+	if rf, ok := log.Formatter.(*RedactingFormatter); ok {
+		rf.AddSecrets(secrets...)
+	}
+}
+
+type byLength []string
+
+func (bl byLength) Len() int           { return len(bl) }
+func (bl byLength) Swap(i, j int)      { bl[i], bl[j] = bl[j], bl[i] }
+func (bl byLength) Less(i, j int) bool { return len(bl[i]) > len(bl[j]) }
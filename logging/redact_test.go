@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+type testAuth struct {
+	AccessKey, SecretKey string
+}
+
+func TestRedactingFormatter(t *testing.T) {
+	log := logrus.New()
+	log.Formatter = NewRedactingFormatter(&MultiLineFormatter{})
+	AddSecrets(log, "AKID", "s3cr3t", "s3cr3t-and-more", "", " ")
+
+	entry := &logrus.Entry{
+		Logger:  log,
+		Level:   logrus.DebugLevel,
+		Message: "using s3cr3t-and-more",
+		Data: logrus.Fields{
+			"auth":  testAuth{AccessKey: "AKID", SecretKey: "s3cr3t"},
+			"err":   errors.New("bad secret s3cr3t"),
+			"url":   "https://example.com/a.txt?X-Amz-Credential=foo&X-Amz-Signature=abc123&x=y",
+			"count": 3,
+		},
+	}
+
+	b, err := log.Formatter.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := string(b)
+	for _, leaked := range []string{"AKID", "s3cr3t", "more", "foo", "abc123"} {
+		if strings.Contains(actual, leaked) {
+			t.Fatalf("%q leaked into %q", leaked, actual)
+		}
+	}
+
+	for _, expected := range []string{
+		"DEBUG: using [REDACTED]\n",
+		"  auth: {[REDACTED] [REDACTED]}\n",
+		"  count: 3\n",
+		"X-Amz-Signature=[REDACTED]&x=y",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Fatalf("%q not in %q", expected, actual)
+		}
+	}
+
+	if entry.Message != "using s3cr3t-and-more" || entry.Data["url"] == Redacted {
+		t.Fatalf("original entry was changed: %v", entry)
+	}
+}
+
+func TestAddSecretsWithoutRedaction(t *testing.T) {
+	log := logrus.New()
+	log.Formatter = &MultiLineFormatter{}
+
+	// must not blow up when redaction is turned off
+	AddSecrets(log, "s3cr3t")
+}
+
+func TestUnwrap(t *testing.T) {
+	text := &logrus.TextFormatter{}
+	if Unwrap(NewRedactingFormatter(NewRedactingFormatter(text))) != text {
+		t.Fatalf("wrapped formatter was not unwrapped")
+	}
+
+	if Unwrap(text) != text {
+		t.Fatalf("unwrapped formatter was changed")
+	}
+}
@@ -0,0 +1,318 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultBuckets are the histogram buckets used when none are given,
+	// in seconds
+	DefaultBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+	// pushClient gives up on a Pushgateway that doesn't answer rather
+	// than holding up the end of the upload
+	pushClient = &http.Client{Timeout: 30 * time.Second}
+
+	// labelValueEscaper escapes what the text format needs escaped in
+	// label values, which isn't quite what Go quoting does
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// Labels are the label names and values of a single series
+type Labels map[string]string
+
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	keys := []string{}
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, k, labelValueEscaper.Replace(l[k])))
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func (l Labels) with(k, v string) Labels {
+	ret := Labels{}
+	for lk, lv := range l {
+		ret[lk] = lv
+	}
+	ret[k] = v
+	return ret
+}
+
+type metric interface {
+	write(io.Writer)
+}
+
+// Registry holds a set of metrics and knows how to write them in the
+// Prometheus text exposition format
+type Registry struct {
+	sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry makes an empty *Registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: []metric{}}
+}
+
+// Counter creates and registers a new *Counter
+func (r *Registry) Counter(name, help string) *Counter {
+	c := &Counter{series: newSeries(name, help, "counter")}
+	r.register(c)
+	return c
+}
+
+// Gauge creates and registers a new *Gauge
+func (r *Registry) Gauge(name, help string) *Gauge {
+	g := &Gauge{series: newSeries(name, help, "gauge")}
+	r.register(g)
+	return g
+}
+
+// Histogram creates and registers a new *Histogram
+func (r *Registry) Histogram(name, help string, buckets []float64) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	sort.Float64s(buckets)
+
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		obs:     map[string]*observations{},
+	}
+	r.register(h)
+	return h
+}
+
+func (r *Registry) register(m metric) {
+	r.Lock()
+	defer r.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes all registered metrics in the text exposition format
+func (r *Registry) Write(w io.Writer) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, m := range r.metrics {
+		m.write(w)
+	}
+}
+
+// WriteFile atomically writes all registered metrics to a file, as
+// expected by e.g. the node_exporter textfile collector
+func (r *Registry) WriteFile(filename string) error {
+	var buf bytes.Buffer
+	r.Write(&buf)
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(buf.Bytes())
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// Push PUTs all registered metrics to a Pushgateway-compatible
+// endpoint under the given job name
+func (r *Registry) Push(baseURL, job string) error {
+	var buf bytes.Buffer
+	r.Write(&buf)
+
+	endpoint := fmt.Sprintf("%s/metrics/job/%s", strings.TrimRight(baseURL, "/"), url.PathEscape(job))
+	req, err := http.NewRequest("PUT", endpoint, &buf)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to push metrics to %s: %s", endpoint, resp.Status)
+	}
+
+	return nil
+}
+
+type series struct {
+	sync.Mutex
+	name   string
+	help   string
+	kind   string
+	values map[string]float64
+}
+
+func newSeries(name, help, kind string) *series {
+	return &series{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		values: map[string]float64{},
+	}
+}
+
+func (s *series) write(w io.Writer) {
+	s.Lock()
+	defer s.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", s.name, s.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", s.name, s.kind)
+
+	keys := []string{}
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", s.name, k, formatFloat(s.values[k]))
+	}
+}
+
+// Counter is a monotonically increasing value per set of labels
+type Counter struct {
+	*series
+}
+
+// Add adds to the counter for the given labels
+func (c *Counter) Add(labels Labels, v float64) {
+	c.Lock()
+	defer c.Unlock()
+	c.values[labels.String()] += v
+}
+
+// Inc adds one to the counter for the given labels
+func (c *Counter) Inc(labels Labels) {
+	c.Add(labels, 1)
+}
+
+// Gauge is an arbitrary value per set of labels
+type Gauge struct {
+	*series
+}
+
+// Set sets the gauge for the given labels
+func (g *Gauge) Set(labels Labels, v float64) {
+	g.Lock()
+	defer g.Unlock()
+	g.values[labels.String()] = v
+}
+
+type observations struct {
+	labels Labels
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram counts observations into cumulative buckets per set of
+// labels
+type Histogram struct {
+	sync.Mutex
+	name    string
+	help    string
+	buckets []float64
+	obs     map[string]*observations
+}
+
+// Observe records a single observation for the given labels
+func (h *Histogram) Observe(labels Labels, v float64) {
+	h.Lock()
+	defer h.Unlock()
+
+	key := labels.String()
+	o, ok := h.obs[key]
+	if !ok {
+		o = &observations{labels: labels, counts: make([]uint64, len(h.buckets))}
+		h.obs[key] = o
+	}
+
+	for i, b := range h.buckets {
+		if v <= b {
+			o.counts[i]++
+		}
+	}
+	o.count++
+	o.sum += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.Lock()
+	defer h.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+
+	keys := []string{}
+	for k := range h.obs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		o := h.obs[k]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, o.labels.with("le", formatFloat(b)), o.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, o.labels.with("le", "+Inf"), o.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, k, formatFloat(o.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, k, o.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func buildTestRegistry() *Registry {
+	r := NewRegistry()
+	c := r.Counter("things_total", "things counted")
+	c.Inc(Labels{"provider": "s3"})
+	c.Add(Labels{"provider": "s3"}, 2)
+
+	g := r.Gauge("level", "level of things")
+	g.Set(nil, 42.5)
+
+	h := r.Histogram("latency_seconds", "latency of things", []float64{1, 5})
+	h.Observe(Labels{"provider": "s3"}, 0.5)
+	h.Observe(Labels{"provider": "s3"}, 3)
+	return r
+}
+
+func TestRegistryWrite(t *testing.T) {
+	var buf bytes.Buffer
+	buildTestRegistry().Write(&buf)
+
+	expected := `# HELP things_total things counted
+# TYPE things_total counter
+things_total{provider="s3"} 3
+# HELP level level of things
+# TYPE level gauge
+level 42.5
+# HELP latency_seconds latency of things
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="1",provider="s3"} 1
+latency_seconds_bucket{le="5",provider="s3"} 2
+latency_seconds_bucket{le="+Inf",provider="s3"} 2
+latency_seconds_sum{provider="s3"} 3.5
+latency_seconds_count{provider="s3"} 2
+`
+
+	if buf.String() != expected {
+		t.Fatalf("%q != %q", buf.String(), expected)
+	}
+}
+
+func TestLabelsString(t *testing.T) {
+	labels := Labels{"path": "a\\b \"c\"\nd\té"}
+	expected := `{path="a\\b \"c\"\nd` + "\té" + `"}`
+
+	if labels.String() != expected {
+		t.Fatalf("%v != %v", labels.String(), expected)
+	}
+}
+
+func TestRegistryWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts-test-metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "artifacts.prom")
+	err = buildTestRegistry().WriteFile(filename)
+	if err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+
+	if !strings.Contains(string(b), "level 42.5\n") {
+		t.Fatalf("unexpected file contents: %q", string(b))
+	}
+}
+
+func TestRegistryPush(t *testing.T) {
+	var (
+		method, path, body string
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(b)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	err := buildTestRegistry().Push(ts.URL+"/", "artifacts")
+	if err != nil {
+		t.Fatalf("error pushing: %v", err)
+	}
+
+	if method != "PUT" {
+		t.Fatalf("method %v != PUT", method)
+	}
+
+	if path != "/metrics/job/artifacts" {
+		t.Fatalf("path %v != /metrics/job/artifacts", path)
+	}
+
+	if !strings.Contains(body, "things_total{provider=\"s3\"} 3\n") {
+		t.Fatalf("unexpected body: %q", body)
+	}
+}
+
+func TestRegistryPushEscapesJob(t *testing.T) {
+	var path string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	err := buildTestRegistry().Push(ts.URL, "travis/artifacts?x")
+	if err != nil {
+		t.Fatalf("error pushing: %v", err)
+	}
+
+	if path != "/metrics/job/travis%2Fartifacts%3Fx" {
+		t.Fatalf("path %v != /metrics/job/travis%%2Fartifacts%%3Fx", path)
+	}
+}
+
+func TestRegistryPushFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	if buildTestRegistry().Push(ts.URL, "artifacts") == nil {
+		t.Fatalf("push to failing endpoint did not error")
+	}
+}
@@ -1,16 +1,30 @@
 package path
 
 import (
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+var (
+	// SettingKeys are the per-path settings that may follow a '?' in a
+	// path spec, e.g. "site/:docs?permissions=public-read"
+	SettingKeys = []string{"permissions", "cache-control", "content-type"}
+
+	settingEscaper = strings.NewReplacer("%", "%25", "&", "%26", ":", "%3A")
+)
+
 // Path is path-like.  Bonkers.
 type Path struct {
 	Root string
 	From string
 	To   string
+
+	Perm         string
+	CacheControl string
+	ContentType  string
 }
 
 // New makes a new *Path.  Crazy!
@@ -22,6 +36,96 @@ func New(root, from, to string) *Path {
 	}
 }
 
+// Parse makes a new *Path from a spec of the form
+// "from[:to][?key=value&...]", where each key is one of SettingKeys.
+// A '?' that isn't followed by only such settings is part of the path.
+func Parse(root, spec string) (*Path, error) {
+	settings := ""
+	for i := 0; i < len(spec); i++ {
+		if spec[i] == '?' && isSettings(spec[i+1:]) {
+			spec, settings = spec[:i], spec[i+1:]
+			break
+		}
+	}
+
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) < 2 {
+		parts = append(parts, "")
+	}
+
+	p := New(root, parts[0], parts[1])
+	if settings == "" {
+		return p, nil
+	}
+
+	for _, setting := range strings.Split(settings, "&") {
+		if strings.TrimSpace(setting) == "" {
+			continue
+		}
+
+		kv := strings.SplitN(setting, "=", 2)
+		value, err := url.PathUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("path %q: %v", spec, err)
+		}
+
+		switch strings.TrimSpace(kv[0]) {
+		case "permissions":
+			p.Perm = value
+		case "cache-control":
+			p.CacheControl = value
+		case "content-type":
+			p.ContentType = value
+		}
+	}
+
+	return p, nil
+}
+
+// isSettings tells if s is made only of key=value pairs whose keys are
+// all SettingKeys
+func isSettings(s string) bool {
+	found := false
+	for _, setting := range strings.Split(s, "&") {
+		if strings.TrimSpace(setting) == "" {
+			continue
+		}
+
+		kv := strings.SplitN(setting, "=", 2)
+		if len(kv) < 2 || !isSettingKey(strings.TrimSpace(kv[0])) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+func isSettingKey(key string) bool {
+	for _, k := range SettingKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatSettings makes the "?key=value&..." suffix of a path spec,
+// or an empty string when settings is empty
+func FormatSettings(settings map[string]string) string {
+	parts := []string{}
+	for _, key := range SettingKeys {
+		if value, ok := settings[key]; ok && value != "" {
+			parts = append(parts, fmt.Sprintf("%s=%s", key, settingEscaper.Replace(value)))
+		}
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return "?" + strings.Join(parts, "&")
+}
+
 // Fullpath returns the full file/dir path
 func (p *Path) Fullpath() string {
 	if p.IsAbs() || strings.HasPrefix(p.From, "/") {
@@ -90,3 +90,81 @@ func TestPathIsDir(t *testing.T) {
 		}
 	}
 }
+
+func TestParse(t *testing.T) {
+	p, err := Parse("/xyz", "site/:docs?permissions=public-read&cache-control=public, max-age=60&content-type=text/html; charset=utf-8")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	for actual, expected := range map[string]string{
+		p.Root:         "/xyz",
+		p.From:         "site/",
+		p.To:           "docs",
+		p.Perm:         "public-read",
+		p.CacheControl: "public, max-age=60",
+		p.ContentType:  "text/html; charset=utf-8",
+	} {
+		if actual != expected {
+			t.Errorf("%q != %q", actual, expected)
+		}
+	}
+
+	p, err = Parse("/xyz", "foo")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	if p.From != "foo" || p.To != "" || p.Perm != "" {
+		t.Errorf("unexpected path %#v", p)
+	}
+
+	if _, err := Parse("/xyz", "foo?permissions=%zz"); err == nil {
+		t.Errorf("invalid spec %q parsed", "foo?permissions=%zz")
+	}
+}
+
+func TestParseQuestionMarks(t *testing.T) {
+	for spec, expected := range map[string]*Path{
+		"what?.txt":                           {From: "what?.txt"},
+		"foo?perms=private":                   {From: "foo?perms=private"},
+		"foo?permissions":                     {From: "foo?permissions"},
+		"foo?":                                {From: "foo?"},
+		"a?b:c?d?permissions=private":         {From: "a?b", To: "c?d", Perm: "private"},
+		"foo?content-type=text/plain?charset": {From: "foo", ContentType: "text/plain?charset"},
+	} {
+		p, err := Parse("/xyz", spec)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", spec, err)
+		}
+
+		if p.From != expected.From || p.To != expected.To || p.Perm != expected.Perm || p.ContentType != expected.ContentType {
+			t.Fatalf("%#v != %#v", p, expected)
+		}
+	}
+}
+
+func TestFormatSettings(t *testing.T) {
+	if FormatSettings(map[string]string{}) != "" {
+		t.Fatalf("empty settings formatted as non-empty")
+	}
+
+	suffix := FormatSettings(map[string]string{
+		"content-type":  "a&b",
+		"permissions":   "public-read",
+		"cache-control": "",
+	})
+
+	if suffix != "?permissions=public-read&content-type=a%26b" {
+		t.Fatalf("unexpected suffix %q", suffix)
+	}
+
+	p, err := Parse("/", "foo"+suffix)
+	if err != nil {
+		t.Fatalf("failed to parse formatted settings: %v", err)
+	}
+
+	if p.ContentType != "a&b" {
+		t.Fatalf("content type %q != %q", p.ContentType, "a&b")
+	}
+}
@@ -0,0 +1,102 @@
+package path
+
+import (
+	"bytes"
+	"fmt"
+	pathpkg "path"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	slugUnsafeRegexp = regexp.MustCompile(`[^a-z0-9._-]+`)
+)
+
+// Context is the build context that target path templates may refer
+// to, e.g. "artifacts/{{ .Branch | slugify }}/{{ .Commit | truncate 7 }}"
+type Context struct {
+	RepoSlug    string
+	BuildNumber string
+	BuildID     string
+	JobNumber   string
+	JobID       string
+	Branch      string
+	Commit      string
+	Tag         string
+	OS          string
+
+	Now time.Time
+}
+
+// Expand executes a target path template against the context.  Strings
+// without any "{{" are returned as they are.
+func Expand(s string, ctx *Context) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	t, err := template.New("path").Funcs(templateFuncs(ctx)).Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %q: %v", s, templateErr(err))
+	}
+
+	var buf bytes.Buffer
+	err = t.Execute(&buf, ctx)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %q: %v", s, templateErr(err))
+	}
+
+	expanded := strings.Trim(pathpkg.Clean("/"+buf.String()), "/")
+	if expanded == "" {
+		return "", fmt.Errorf("template %q expands to nothing", s)
+	}
+
+	return expanded, nil
+}
+
+func templateFuncs(ctx *Context) template.FuncMap {
+	return template.FuncMap{
+		"lower":    strings.ToLower,
+		"upper":    strings.ToUpper,
+		"slugify":  slugify,
+		"truncate": truncate,
+		"default":  defaultString,
+		// date formats the build's time in UTC with a Go layout, e.g.
+		// {{ date "2006/01/02" }}
+		"date": func(layout string) string {
+			return ctx.Now.UTC().Format(layout)
+		},
+	}
+}
+
+// slugify makes a string safe for a single path segment, e.g.
+// "feature/Foo Bar" becomes "feature-foo-bar"
+func slugify(s string) string {
+	return strings.Trim(slugUnsafeRegexp.ReplaceAllString(strings.ToLower(s), "-"), "-.")
+}
+
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if n < 0 || len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+func defaultString(dflt, s string) string {
+	if s == "" {
+		return dflt
+	}
+	return s
+}
+
+// templateErr strips the "template: path:1:" noise from errors
+func templateErr(err error) string {
+	msg := strings.TrimPrefix(err.Error(), "template: ")
+	if i := strings.Index(msg, ": "); i >= 0 {
+		msg = msg[i+2:]
+	}
+	return strings.TrimPrefix(msg, `executing "path" `)
+}
@@ -0,0 +1,47 @@
+package path
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	ctx := &Context{
+		RepoSlug:    "owner/Repo",
+		BuildNumber: "12",
+		Branch:      "feature/Foo Bar",
+		Commit:      "0123456789abcdef",
+		OS:          "linux",
+		Now:         time.Date(2015, 3, 20, 13, 0, 0, 0, time.UTC),
+	}
+
+	for tmpl, expected := range map[string]string{
+		"artifacts/$TRAVIS_BUILD_NUMBER":                       "artifacts/$TRAVIS_BUILD_NUMBER",
+		"artifacts/{{ .RepoSlug | lower }}/{{ .BuildNumber }}": "artifacts/owner/repo/12",
+		"{{ .Branch | slugify }}/{{ .Commit | truncate 7 }}":   "feature-foo-bar/0123456",
+		"nightly/{{ date \"2006/01/02\" }}/{{ .OS }}":          "nightly/2015/03/20/linux",
+		"releases/{{ .Tag | default \"untagged\" }}":           "releases/untagged",
+		"artifacts/{{ .Tag }}/{{ .BuildNumber }}":              "artifacts/12",
+	} {
+		expanded, err := Expand(tmpl, ctx)
+		if err != nil {
+			t.Fatalf("%q: %v", tmpl, err)
+		}
+
+		if expanded != expected {
+			t.Fatalf("%q: %v != %v", tmpl, expanded, expected)
+		}
+	}
+
+	for tmpl, msg := range map[string]string{
+		"{{ .Branch | slugfy }}": `function "slugfy" not defined`,
+		"{{ .Brnch }}":           "can't evaluate field Brnch",
+		"{{ .Tag }}":             "expands to nothing",
+	} {
+		_, err := Expand(tmpl, ctx)
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%q: unexpected error %v", tmpl, err)
+		}
+	}
+}
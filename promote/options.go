@@ -0,0 +1,160 @@
+package promote
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/dustin/go-humanize"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/remote"
+	"github.com/travis-ci/artifacts/summary"
+)
+
+const (
+	// CommandDescription is the string used to describe the
+	// "promote" command in the command line help system
+	CommandDescription = `
+Copy every artifact under one prefix to another within the bucket, e.g. to
+promote a passing build's artifacts to a release, using S3 server-side copies.
+Metadata, content type and permissions are kept unless new ones are given.
+`
+
+	minPartSize = uint64(1024 * 1024 * 5)
+	maxPartSize = uint64(1024 * 1024 * 1024 * 5)
+)
+
+var (
+	perms = map[string]bool{
+		string(s3.Private):           true,
+		string(s3.PublicRead):        true,
+		string(s3.PublicReadWrite):   true,
+		string(s3.AuthenticatedRead): true,
+		string(s3.BucketOwnerRead):   true,
+		string(s3.BucketOwnerFull):   true,
+	}
+)
+
+// Options is used in the call to Promote
+type Options struct {
+	remote.Options
+
+	From          string
+	To            string
+	Perm          string
+	ContentType   string
+	CacheControl  string
+	PartSize      uint64
+	Concurrency   uint64
+	Retries       uint64
+	FailurePolicy string
+}
+
+// NewOptions makes some *Options with defaults!
+func NewOptions() *Options {
+	return &Options{
+		Options:       remote.NewOptions(),
+		PartSize:      1024 * 1024 * 1024,
+		Concurrency:   5,
+		Retries:       2,
+		FailurePolicy: summary.FailurePolicyAny,
+	}
+}
+
+// Flags returns the command line flags of the promote command
+func Flags() []cli.Flag {
+	return append(remote.Flags(),
+		cli.StringFlag{Name: "from", Usage: "prefix to copy from *REQUIRED*"},
+		cli.StringFlag{Name: "to", Usage: "prefix to copy to *REQUIRED*"},
+		cli.StringFlag{Name: "permissions", Usage: "permissions for the copies (default: those of each artifact)"},
+		cli.StringFlag{Name: "content-type", Usage: "content type for the copies (default: that of each artifact)"},
+		cli.StringFlag{Name: "cache-control", Usage: "cache control for the copies (default: that of each artifact)"},
+		cli.StringFlag{Name: "part-size", Usage: "copy artifacts bigger than this in parts of this size (default 1GiB)"},
+		cli.StringFlag{Name: "concurrency", Usage: "copy worker concurrency (default 5)"},
+		cli.StringFlag{Name: "retries", Usage: "number of copy retries per artifact (default 2)"},
+		cli.StringFlag{Name: "failure-policy", Usage: "when failed copies fail the run (ignore, any, all) (default \"any\")"})
+}
+
+// UpdateFromCLI overlays a *cli.Context onto the options
+func (opts *Options) UpdateFromCLI(c *cli.Context) error {
+	opts.Options.UpdateFromCLI(c)
+
+	for name, field := range map[string]*string{
+		"from":           &opts.From,
+		"to":             &opts.To,
+		"permissions":    &opts.Perm,
+		"content-type":   &opts.ContentType,
+		"cache-control":  &opts.CacheControl,
+		"failure-policy": &opts.FailurePolicy,
+	} {
+		if value := strings.TrimSpace(c.String(name)); value != "" {
+			*field = value
+		}
+	}
+
+	for name, field := range map[string]*uint64{
+		"part-size":   &opts.PartSize,
+		"concurrency": &opts.Concurrency,
+		"retries":     &opts.Retries,
+	} {
+		value := c.String(name)
+		if value == "" {
+			continue
+		}
+
+		parse := func(s string) (uint64, error) { return strconv.ParseUint(s, 10, 64) }
+		if name == "part-size" {
+			parse = humanize.ParseBytes
+		}
+
+		n, err := parse(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", name, value)
+		}
+		*field = n
+	}
+
+	return nil
+}
+
+// Validate checks for validity!
+func (opts *Options) Validate() error {
+	err := opts.Options.Validate()
+	if err != nil {
+		return err
+	}
+
+	if opts.From == "" || opts.To == "" {
+		return fmt.Errorf("both --from and --to prefixes are required")
+	}
+
+	from, to := prefix(opts.From), prefix(opts.To)
+	if strings.HasPrefix(to, from) || strings.HasPrefix(from, to) {
+		return fmt.Errorf("prefixes %q and %q overlap", opts.From, opts.To)
+	}
+
+	if opts.Perm != "" && !perms[opts.Perm] {
+		return fmt.Errorf("invalid permissions %q", opts.Perm)
+	}
+
+	if opts.PartSize < minPartSize || opts.PartSize > maxPartSize {
+		return fmt.Errorf("part size must be between 5MiB and 5GiB")
+	}
+
+	if opts.Concurrency == 0 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+
+	if !summary.FailurePolicies[opts.FailurePolicy] {
+		return fmt.Errorf("invalid failure policy %q", opts.FailurePolicy)
+	}
+
+	return nil
+}
+
+// prefix makes sure a prefix ends in "/" so that "v1.2" does not also
+// match "v1.20"
+func prefix(p string) string {
+	return strings.TrimRight(strings.TrimLeft(p, "/"), "/") + "/"
+}
@@ -0,0 +1,181 @@
+package promote
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/remote"
+	"github.com/travis-ci/artifacts/summary"
+)
+
+const (
+	providerName = "s3"
+)
+
+var (
+	retryInterval = 3 * time.Second
+)
+
+type promoter struct {
+	Opts *Options
+
+	bucket *s3.Bucket
+	log    *logrus.Logger
+}
+
+type copyResult struct {
+	Src     string
+	Dest    string
+	Err     error
+	Retries uint64
+}
+
+// Promote copies everything under one prefix to another
+func Promote(opts *Options, log *logrus.Logger) error {
+	bucket, err := opts.Bucket()
+	if err != nil {
+		return err
+	}
+
+	return (&promoter{Opts: opts, bucket: bucket, log: log}).Promote()
+}
+
+func (p *promoter) Promote() error {
+	from, to := prefix(p.Opts.From), prefix(p.Opts.To)
+
+	keys, err := remote.Keys(p.bucket, from)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("no artifacts under %q", from)
+	}
+
+	p.log.WithFields(logrus.Fields{
+		"bucket":      p.Opts.BucketName,
+		"from":        from,
+		"to":          to,
+		"artifacts":   len(keys),
+		"permissions": p.Opts.Perm,
+	}).Info("promoting with settings")
+
+	in := make(chan s3.Key)
+	out := make(chan *copyResult)
+	var wg sync.WaitGroup
+
+	for i := uint64(0); i < p.Opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range in {
+				out <- p.copyWithRetries(k, destKey(k.Key, from, to))
+			}
+		}()
+	}
+
+	go func() {
+		for _, k := range keys {
+			in <- k
+		}
+		close(in)
+		wg.Wait()
+		close(out)
+	}()
+
+	s := summary.New("promote", "copied", []string{providerName})
+	for result := range out {
+		s.Record(providerName, fmt.Sprintf("%s -> %s", result.Src, result.Dest),
+			result.Err == nil, result.Retries)
+
+		if result.Err != nil {
+			p.log.WithFields(logrus.Fields{
+				"dest": result.Dest,
+				"err":  result.Err,
+			}).Error(fmt.Sprintf("failed to copy: %s", result.Src))
+			continue
+		}
+
+		p.log.WithField("dest", result.Dest).Info(fmt.Sprintf("copied: %s", result.Src))
+	}
+
+	s.Log(p.log)
+	return s.Err(p.Opts.FailurePolicy)
+}
+
+func (p *promoter) copyWithRetries(k s3.Key, dest string) *copyResult {
+	result := &copyResult{Src: k.Key, Dest: dest}
+
+	for {
+		result.Err = p.copy(k, dest)
+		if result.Err == nil || result.Retries >= p.Opts.Retries {
+			return result
+		}
+
+		result.Retries++
+		p.log.WithFields(logrus.Fields{
+			"artifact": k.Key,
+			"retry":    result.Retries,
+			"err":      result.Err,
+		}).Debug("retrying")
+		time.Sleep(retryInterval)
+	}
+}
+
+func (p *promoter) copy(k s3.Key, dest string) error {
+	headers, err := remote.Head(p.bucket, k.Key)
+	if err != nil {
+		return err
+	}
+
+	perm := s3.ACL(p.Opts.Perm)
+	if perm == "" {
+		perm, err = remote.ACL(p.bucket, k.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	headers = p.overrideHeaders(headers)
+
+	p.log.WithFields(logrus.Fields{
+		"src":          k.Key,
+		"dest":         dest,
+		"size":         k.Size,
+		"content_type": headers.Get("Content-Type"),
+		"permissions":  perm,
+	}).Debug("more artifact details")
+
+	return remote.Copy(p.bucket, k.Key, dest, k.Size, int64(p.Opts.PartSize), headers, perm)
+}
+
+// overrideHeaders applies the new content type and cache control, and
+// points redirects for symlinks within the old prefix to the new one
+func (p *promoter) overrideHeaders(headers http.Header) http.Header {
+	if p.Opts.ContentType != "" {
+		headers.Set("Content-Type", p.Opts.ContentType)
+	}
+
+	if p.Opts.CacheControl != "" {
+		headers.Set("Cache-Control", p.Opts.CacheControl)
+	}
+
+	const redirect = "X-Amz-Website-Redirect-Location"
+	if location := headers.Get(redirect); location != "" {
+		from, to := "/"+prefix(p.Opts.From), "/"+prefix(p.Opts.To)
+		if strings.HasPrefix(location, from) {
+			headers.Set(redirect, to+strings.TrimPrefix(location, from))
+		}
+	}
+
+	return headers
+}
+
+func destKey(key, from, to string) string {
+	return to + strings.TrimPrefix(key, from)
+}
@@ -0,0 +1,67 @@
+package promote
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	opts := NewOptions()
+	opts.BucketName = "foo"
+	opts.S3Region = "us-east-1"
+	opts.From = "artifacts/123"
+	opts.To = "releases/v1.2.3/"
+
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		from, to string
+	}{
+		{"artifacts/", "artifacts/releases"},
+		{"releases/v1.2", "releases"},
+		{"", "releases"},
+	} {
+		opts.From, opts.To = tc.from, tc.to
+		if opts.Validate() == nil {
+			t.Fatalf("%q -> %q was valid", tc.from, tc.to)
+		}
+	}
+
+	opts.From, opts.To = "releases/v1.2", "releases/v1.20"
+	opts.Perm = "world-writable"
+	if opts.Validate() == nil {
+		t.Fatalf("invalid permissions were valid")
+	}
+}
+
+func TestPromoterOverrideHeaders(t *testing.T) {
+	opts := NewOptions()
+	opts.From = "artifacts/123"
+	opts.To = "releases/v1.2.3"
+	opts.CacheControl = "public, max-age=31536000"
+
+	p := &promoter{Opts: opts}
+	headers := p.overrideHeaders(http.Header{
+		"Content-Type":                    {"text/plain"},
+		"Cache-Control":                   {"private"},
+		"X-Amz-Meta-Symlink-Target":       {"a.txt"},
+		"X-Amz-Website-Redirect-Location": {"/artifacts/123/logs/a.txt"},
+	})
+
+	for name, expected := range map[string]string{
+		"Content-Type":                    "text/plain",
+		"Cache-Control":                   "public, max-age=31536000",
+		"X-Amz-Meta-Symlink-Target":       "a.txt",
+		"X-Amz-Website-Redirect-Location": "/releases/v1.2.3/logs/a.txt",
+	} {
+		if headers.Get(name) != expected {
+			t.Fatalf("%s: %v != %v", name, headers.Get(name), expected)
+		}
+	}
+
+	if destKey("artifacts/123/logs/a.txt", "artifacts/123/", "releases/v1.2.3/") != "releases/v1.2.3/logs/a.txt" {
+		t.Fatalf("unexpected dest key")
+	}
+}
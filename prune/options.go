@@ -0,0 +1,134 @@
+package prune
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/travis-ci/artifacts/remote"
+)
+
+const (
+	// CommandDescription is the string used to describe the
+	// "prune" command in the command line help system
+	CommandDescription = `
+Delete old artifacts from S3.  Keys are matched against the target path
+template, e.g. "artifacts/$TRAVIS_REPO_SLUG/$TRAVIS_BRANCH/$TRAVIS_BUILD_NUMBER"
+or "artifacts/{{ .RepoSlug }}/{{ .Branch }}/{{ .BuildNumber }}", to tell which
+repo slug, branch and build they belong to.  Artifacts older than
+--older-than days are deleted, as are those of all but the last --keep-builds
+builds per repo slug and branch.  Content-addressed blobs under --cas-prefix
+and latest aliases under --latest-prefix are never matched; blobs are deleted
+once no remaining pointer refers to them.  Use --dry-run to list what would be
+deleted.
+`
+)
+
+// Options is used in the call to Prune
+type Options struct {
+	remote.Options
+
+	TargetPath   string
+	OlderThan    uint64
+	KeepBuilds   uint64
+	DryRun       bool
+	MaxDeletions uint64
+	CASPrefix    string
+	LatestPrefix string
+}
+
+// NewOptions makes some *Options with defaults!
+func NewOptions() *Options {
+	return &Options{
+		Options:      remote.NewOptions(),
+		TargetPath:   "artifacts/$TRAVIS_BUILD_NUMBER/$TRAVIS_JOB_NUMBER",
+		MaxDeletions: 1000,
+		CASPrefix:    "artifacts/cas",
+		LatestPrefix: "latest",
+	}
+}
+
+// Flags returns the command line flags of the prune command
+func Flags() []cli.Flag {
+	return append(remote.Flags(),
+		cli.StringFlag{Name: "target-path, t", Usage: "target path template, with variables left unexpanded"},
+		cli.StringFlag{Name: "older-than", Usage: "delete artifacts older than this many days"},
+		cli.StringFlag{Name: "keep-builds", Usage: "keep only this many most recent builds per repo slug and branch"},
+		cli.BoolFlag{Name: "dry-run", Usage: "list what would be deleted without deleting it"},
+		cli.StringFlag{Name: "max-deletions", Usage: "refuse to delete more than this many artifacts in one run (default 1000)"},
+		cli.StringFlag{Name: "cas-prefix", Usage: "prefix that content-addressed blobs are stored under (default artifacts/cas)"},
+		cli.StringFlag{Name: "latest-prefix", Usage: "prefix that latest aliases are published under (default latest)"})
+}
+
+// UpdateFromCLI overlays a *cli.Context onto the options
+func (opts *Options) UpdateFromCLI(c *cli.Context) error {
+	opts.Options.UpdateFromCLI(c)
+
+	for name, field := range map[string]*string{
+		"target-path":   &opts.TargetPath,
+		"cas-prefix":    &opts.CASPrefix,
+		"latest-prefix": &opts.LatestPrefix,
+	} {
+		if value := c.String(name); value != "" {
+			*field = value
+		}
+	}
+
+	if c.Bool("dry-run") {
+		opts.DryRun = true
+	}
+
+	for name, field := range map[string]*uint64{
+		"older-than":    &opts.OlderThan,
+		"keep-builds":   &opts.KeepBuilds,
+		"max-deletions": &opts.MaxDeletions,
+	} {
+		value := c.String(name)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", name, value)
+		}
+		*field = n
+	}
+
+	return nil
+}
+
+// protectedPrefixes are where the keys that no build owns live
+func (opts *Options) protectedPrefixes() []string {
+	prefixes := []string{}
+	for _, prefix := range []string{opts.CASPrefix, opts.LatestPrefix} {
+		if prefix = strings.Trim(prefix, "/"); prefix != "" {
+			prefixes = append(prefixes, prefix+"/")
+		}
+	}
+	return prefixes
+}
+
+// Validate checks for validity!
+func (opts *Options) Validate() error {
+	err := opts.Options.Validate()
+	if err != nil {
+		return err
+	}
+
+	if opts.OlderThan == 0 && opts.KeepBuilds == 0 {
+		return fmt.Errorf("one of --older-than or --keep-builds is required")
+	}
+
+	t, err := parseTemplate(opts.TargetPath)
+	if err != nil {
+		return err
+	}
+
+	if opts.KeepBuilds > 0 && !t.HasBuild() {
+		return fmt.Errorf("target path template %q has no build number to keep builds by", opts.TargetPath)
+	}
+
+	return nil
+}
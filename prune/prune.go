@@ -0,0 +1,291 @@
+package prune
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/cas"
+	"github.com/travis-ci/artifacts/remote"
+)
+
+// doomed is a key to be deleted and why
+type doomed struct {
+	Key    string
+	Reason string
+}
+
+// Prune deletes the artifacts that are past retention
+func Prune(opts *Options, log *logrus.Logger) error {
+	t, err := parseTemplate(opts.TargetPath)
+	if err != nil {
+		return err
+	}
+
+	bucket, err := opts.Bucket()
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(logrus.Fields{
+		"bucket": opts.BucketName,
+		"prefix": t.Prefix(),
+	}).Debug("listing artifacts")
+
+	keys, err := remote.Keys(bucket, t.Prefix())
+	if err != nil {
+		return err
+	}
+
+	doomedKeys := selectDoomed(t, keys, opts.protectedPrefixes(), time.Now(), opts.OlderThan, opts.KeepBuilds)
+
+	doomedBlobs, err := selectDoomedBlobs(bucket, opts.CASPrefix, keys, doomedKeys)
+	if err != nil {
+		return err
+	}
+	doomedKeys = append(doomedKeys, doomedBlobs...)
+
+	log.WithFields(logrus.Fields{
+		"listed":   len(keys),
+		"doomed":   len(doomedKeys),
+		"dry_run":  opts.DryRun,
+		"template": opts.TargetPath,
+	}).Info(fmt.Sprintf("%d of %d artifacts past retention", len(doomedKeys), len(keys)))
+
+	if opts.DryRun {
+		for _, d := range doomedKeys {
+			log.WithField("reason", d.Reason).Info(fmt.Sprintf("would delete: %s", d.Key))
+		}
+		return nil
+	}
+
+	if uint64(len(doomedKeys)) > opts.MaxDeletions {
+		return fmt.Errorf("refusing to delete %d artifacts, more than max deletions %d",
+			len(doomedKeys), opts.MaxDeletions)
+	}
+
+	return deleteKeys(bucket, doomedKeys, log)
+}
+
+// selectDoomed picks the keys matching the template that are either
+// older than olderThan days or not among the last keepBuilds builds of
+// their repo slug and branch; zero disables either check.  Keys under
+// the protected prefixes belong to no build and are never picked.
+func selectDoomed(t *template, keys []s3.Key, protected []string, now time.Time, olderThan, keepBuilds uint64) []*doomed {
+	cutoff := now.Add(-time.Duration(olderThan) * 24 * time.Hour)
+	matches := map[string]*match{}
+	builds := map[string][]string{}
+
+	for _, k := range keys {
+		if hasAnyPrefix(k.Key, protected) {
+			continue
+		}
+
+		m, ok := t.Match(k.Key)
+		if !ok {
+			continue
+		}
+		matches[k.Key] = m
+
+		if !containsString(builds[m.Group], m.Build) {
+			builds[m.Group] = append(builds[m.Group], m.Build)
+		}
+	}
+
+	kept := map[string]bool{}
+	for group, groupBuilds := range builds {
+		sort.Sort(sort.Reverse(buildNumbers(groupBuilds)))
+		for i, build := range groupBuilds {
+			if uint64(i) < keepBuilds {
+				kept[group+"\x00"+build] = true
+			}
+		}
+	}
+
+	doomedKeys := []*doomed{}
+	for _, k := range keys {
+		m, ok := matches[k.Key]
+		if !ok {
+			continue
+		}
+
+		if keepBuilds > 0 && !kept[m.Group+"\x00"+m.Build] {
+			doomedKeys = append(doomedKeys, &doomed{
+				Key:    k.Key,
+				Reason: fmt.Sprintf("build %s is not among the last %d", m.Build, keepBuilds),
+			})
+			continue
+		}
+
+		if olderThan == 0 {
+			continue
+		}
+
+		modified, err := time.Parse(time.RFC3339, k.LastModified)
+		if err == nil && modified.Before(cutoff) {
+			doomedKeys = append(doomedKeys, &doomed{
+				Key:    k.Key,
+				Reason: fmt.Sprintf("older than %d days", olderThan),
+			})
+		}
+	}
+
+	return doomedKeys
+}
+
+// selectDoomedBlobs picks the blobs that doomed pointers refer to and
+// that no other pointer in the bucket still does, which takes a HEAD
+// request per pointer as only their metadata says which blob they are for
+func selectDoomedBlobs(bucket *s3.Bucket, casPrefix string, listed []s3.Key, doomedKeys []*doomed) ([]*doomed, error) {
+	casPrefix = strings.Trim(casPrefix, "/") + "/"
+	if casPrefix == "/" || len(doomedKeys) == 0 {
+		return []*doomed{}, nil
+	}
+
+	doomedSet := map[string]bool{}
+	for _, d := range doomedKeys {
+		doomedSet[d.Key] = true
+	}
+
+	doomedPointers := map[string]string{}
+	for _, k := range listed {
+		if k.Size != 0 || !doomedSet[k.Key] {
+			continue
+		}
+
+		blob, err := pointerBlob(bucket, k.Key)
+		if err != nil {
+			return nil, err
+		}
+		if blob != "" {
+			doomedPointers[k.Key] = blob
+		}
+	}
+
+	if len(doomedPointers) == 0 {
+		return []*doomed{}, nil
+	}
+
+	// pointers may be anywhere, not just under the template
+	all, err := remote.Keys(bucket, "")
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := map[string]bool{}
+	surviving := map[string]string{}
+	for _, k := range all {
+		if strings.HasPrefix(k.Key, casPrefix) {
+			blobs[k.Key] = true
+			continue
+		}
+
+		if k.Size != 0 || doomedSet[k.Key] {
+			continue
+		}
+
+		blob, err := pointerBlob(bucket, k.Key)
+		if err != nil {
+			return nil, err
+		}
+		if blob != "" {
+			surviving[k.Key] = blob
+		}
+	}
+
+	return unreferencedBlobs(doomedPointers, surviving, blobs), nil
+}
+
+// unreferencedBlobs picks the existing blobs of the doomed pointers that
+// none of the surviving ones refer to
+func unreferencedBlobs(doomedPointers, surviving map[string]string, blobs map[string]bool) []*doomed {
+	referenced := map[string]bool{}
+	for _, blob := range surviving {
+		referenced[blob] = true
+	}
+
+	unreferenced := []string{}
+	for _, blob := range doomedPointers {
+		if blobs[blob] && !referenced[blob] && !containsString(unreferenced, blob) {
+			unreferenced = append(unreferenced, blob)
+		}
+	}
+	sort.Strings(unreferenced)
+
+	doomedBlobs := []*doomed{}
+	for _, blob := range unreferenced {
+		doomedBlobs = append(doomedBlobs, &doomed{
+			Key:    blob,
+			Reason: "blob is no longer referenced by any pointer",
+		})
+	}
+	return doomedBlobs
+}
+
+// pointerBlob returns the blob the key is a pointer to, if it is one
+func pointerBlob(bucket *s3.Bucket, key string) (string, error) {
+	headers, err := remote.Head(bucket, key)
+	if remote.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	blob, _ := cas.Resolve(headers)
+	return blob, nil
+}
+
+func deleteKeys(bucket *s3.Bucket, doomedKeys []*doomed, log *logrus.Logger) error {
+	keys := []string{}
+	for _, d := range doomedKeys {
+		keys = append(keys, d.Key)
+	}
+
+	err := remote.Delete(bucket, keys)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range doomedKeys {
+		log.WithField("reason", d.Reason).Info(fmt.Sprintf("deleted: %s", d.Key))
+	}
+
+	return nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// buildNumbers sorts numerically where it can
+type buildNumbers []string
+
+func (bn buildNumbers) Len() int      { return len(bn) }
+func (bn buildNumbers) Swap(i, j int) { bn[i], bn[j] = bn[j], bn[i] }
+func (bn buildNumbers) Less(i, j int) bool {
+	a, aErr := strconv.ParseUint(bn[i], 10, 64)
+	b, bErr := strconv.ParseUint(bn[j], 10, 64)
+	if aErr == nil && bErr == nil {
+		return a < b
+	}
+	return bn[i] < bn[j]
+}
@@ -0,0 +1,195 @@
+package prune
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mitchellh/goamz/s3"
+)
+
+func TestTemplateMatch(t *testing.T) {
+	tmpl, err := parseTemplate("artifacts/$TRAVIS_REPO_SLUG/${TRAVIS_BRANCH}/$TRAVIS_BUILD_NUMBER/$TRAVIS_JOB_NUMBER")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tmpl.Prefix() != "artifacts/" {
+		t.Fatalf("%v != artifacts/", tmpl.Prefix())
+	}
+
+	m, ok := tmpl.Match("artifacts/owner/repo/master/12/12.1/logs/test.log")
+	if !ok {
+		t.Fatalf("key did not match")
+	}
+
+	if m.Group != "owner/repo master" || m.Build != "12" {
+		t.Fatalf("unexpected match %#v", m)
+	}
+
+	for _, key := range []string{
+		"other/owner/repo/master/12/12.1/test.log",
+		"artifacts/owner/repo/master/12/12.1",
+	} {
+		if _, ok := tmpl.Match(key); ok {
+			t.Fatalf("%q matched", key)
+		}
+	}
+
+	if _, err := parseTemplate("artifacts/build-$TRAVIS_BUILD_NUMBER"); err == nil {
+		t.Fatalf("partial variable segment was accepted")
+	}
+}
+
+func TestTemplateMatchActions(t *testing.T) {
+	tmpl, err := parseTemplate(`artifacts/{{ .RepoSlug }}/{{ .Branch | slugify }}/{{ date "2006/01/02" }}/{{.BuildNumber}}/{{ .JobNumber }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tmpl.Prefix() != "artifacts/" {
+		t.Fatalf("%v != artifacts/", tmpl.Prefix())
+	}
+
+	m, ok := tmpl.Match("artifacts/owner/repo/feature-foo/2015/03/20/12/12.1/logs/test.log")
+	if !ok {
+		t.Fatalf("key did not match")
+	}
+
+	if m.Group != "owner/repo feature-foo" || m.Build != "12" {
+		t.Fatalf("unexpected match %#v", m)
+	}
+
+	for _, s := range []string{
+		"artifacts/build-{{ .BuildNumber }}",
+		"artifacts/{{ if .Tag }}tags{{ end }}",
+		"artifacts/{{ .Nope }}",
+	} {
+		if _, err := parseTemplate(s); err == nil {
+			t.Fatalf("%q was accepted", s)
+		}
+	}
+}
+
+func TestSelectDoomed(t *testing.T) {
+	tmpl, err := parseTemplate("artifacts/$TRAVIS_BRANCH/$TRAVIS_BUILD_NUMBER")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2015, 3, 20, 0, 0, 0, 0, time.UTC)
+	old := "2015-03-01T00:00:00.000Z"
+	recent := "2015-03-19T00:00:00.000Z"
+
+	keys := []s3.Key{
+		{Key: "artifacts/master/9/a.txt", LastModified: old},
+		{Key: "artifacts/master/10/a.txt", LastModified: recent},
+		{Key: "artifacts/master/10/b.txt", LastModified: recent},
+		{Key: "artifacts/master/11/a.txt", LastModified: recent},
+		{Key: "artifacts/dev/3/a.txt", LastModified: old},
+		{Key: "artifacts/README", LastModified: old},
+	}
+
+	for _, tc := range []struct {
+		olderThan  uint64
+		keepBuilds uint64
+		expected   []string
+	}{
+		{
+			olderThan: 7,
+			expected:  []string{"artifacts/master/9/a.txt", "artifacts/dev/3/a.txt"},
+		},
+		{
+			keepBuilds: 2,
+			expected:   []string{"artifacts/master/9/a.txt"},
+		},
+		{
+			keepBuilds: 1,
+			expected: []string{
+				"artifacts/master/9/a.txt", "artifacts/master/10/a.txt",
+				"artifacts/master/10/b.txt",
+			},
+		},
+		{
+			olderThan:  7,
+			keepBuilds: 1,
+			expected: []string{
+				"artifacts/master/9/a.txt", "artifacts/master/10/a.txt",
+				"artifacts/master/10/b.txt", "artifacts/dev/3/a.txt",
+			},
+		},
+	} {
+		doomedKeys := selectDoomed(tmpl, keys, []string{}, now, tc.olderThan, tc.keepBuilds)
+		if len(doomedKeys) != len(tc.expected) {
+			t.Fatalf("%d/%d: %d != %d", tc.olderThan, tc.keepBuilds, len(doomedKeys), len(tc.expected))
+		}
+
+		for i, d := range doomedKeys {
+			if d.Key != tc.expected[i] {
+				t.Fatalf("%d/%d: %v != %v", tc.olderThan, tc.keepBuilds, d.Key, tc.expected[i])
+			}
+		}
+	}
+}
+
+func TestSelectDoomedSkipsProtectedKeys(t *testing.T) {
+	tmpl, err := parseTemplate("artifacts/$TRAVIS_BUILD_NUMBER/$TRAVIS_JOB_NUMBER")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2015, 3, 20, 0, 0, 0, 0, time.UTC)
+	old := "2015-03-01T00:00:00.000Z"
+	recent := "2015-03-19T00:00:00.000Z"
+
+	keys := []s3.Key{
+		{Key: "artifacts/9/9.1/a.txt", LastModified: old},
+		{Key: "artifacts/10/10.1/a.txt", LastModified: recent},
+		{Key: "artifacts/cas/sha256/ab/abcdef", LastModified: old},
+		{Key: "latest/master/a.txt", LastModified: old},
+	}
+
+	opts := NewOptions()
+	opts.TargetPath = "$TRAVIS_BUILD_NUMBER/$TRAVIS_JOB_NUMBER"
+
+	doomedKeys := selectDoomed(tmpl, keys, opts.protectedPrefixes(), now, 7, 1)
+	if len(doomedKeys) != 1 || doomedKeys[0].Key != "artifacts/9/9.1/a.txt" {
+		t.Fatalf("unexpected doomed keys %v", doomedKeys)
+	}
+
+	wide, err := parseTemplate(opts.TargetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range selectDoomed(wide, keys, opts.protectedPrefixes(), now, 7, 0) {
+		if hasAnyPrefix(d.Key, []string{"artifacts/cas/", "latest/"}) {
+			t.Fatalf("protected key %v was doomed", d.Key)
+		}
+	}
+}
+
+func TestUnreferencedBlobs(t *testing.T) {
+	doomedPointers := map[string]string{
+		"artifacts/9/9.1/a.txt": "artifacts/cas/sha256/aa/aa",
+		"artifacts/9/9.1/b.txt": "artifacts/cas/sha256/bb/bb",
+		"artifacts/9/9.1/c.txt": "artifacts/cas/sha256/cc/cc",
+		"artifacts/9/9.1/d.txt": "artifacts/cas/sha256/aa/aa",
+	}
+	surviving := map[string]string{
+		"artifacts/10/10.1/b.txt": "artifacts/cas/sha256/bb/bb",
+		"latest/master/b.txt":     "artifacts/cas/sha256/bb/bb",
+	}
+	blobs := map[string]bool{
+		"artifacts/cas/sha256/aa/aa": true,
+		"artifacts/cas/sha256/bb/bb": true,
+	}
+
+	doomedBlobs := unreferencedBlobs(doomedPointers, surviving, blobs)
+	if len(doomedBlobs) != 1 {
+		t.Fatalf("doomed blobs length %v != 1", len(doomedBlobs))
+	}
+
+	if doomedBlobs[0].Key != "artifacts/cas/sha256/aa/aa" {
+		t.Fatalf("%v != artifacts/cas/sha256/aa/aa", doomedBlobs[0].Key)
+	}
+}
@@ -0,0 +1,210 @@
+package prune
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	variableRegexp = regexp.MustCompile(`^\$\{?([A-Za-z_][A-Za-z0-9_]*)\}?$`)
+	actionRegexp   = regexp.MustCompile(`^\{\{-?\s*(.*?)\s*-?\}\}$`)
+	fieldRegexp    = regexp.MustCompile(`^\.([A-Za-z]+)$`)
+	dateRegexp     = regexp.MustCompile(`^date\s+"([^"]*)"$`)
+
+	// fieldVariables are the env variables that the fields of the
+	// upload command's {{ ... }} templates stand for
+	fieldVariables = map[string]string{
+		"RepoSlug":    "TRAVIS_REPO_SLUG",
+		"BuildNumber": "TRAVIS_BUILD_NUMBER",
+		"BuildID":     "TRAVIS_BUILD_ID",
+		"JobNumber":   "TRAVIS_JOB_NUMBER",
+		"JobID":       "TRAVIS_JOB_ID",
+		"Branch":      "TRAVIS_BRANCH",
+		"Commit":      "TRAVIS_COMMIT",
+		"Tag":         "TRAVIS_TAG",
+		"OS":          "TRAVIS_OS_NAME",
+	}
+)
+
+// segment is a literal or a variable spanning width path segments
+type segment struct {
+	literal  string
+	variable string
+	width    int
+}
+
+// template is a target path template like the upload command's
+// default "artifacts/{{ .BuildNumber }}/{{ .JobNumber }}", used to tell
+// which build, repo slug and branch each key belongs to
+type template struct {
+	segments []*segment
+}
+
+// match is what a key's path tells about it
+type match struct {
+	Group string
+	Build string
+}
+
+// parseTemplate understands both $VAR variables and the upload
+// command's {{ ... }} actions, as long as either takes up whole segments
+func parseTemplate(s string) (*template, error) {
+	t := &template{segments: []*segment{}}
+
+	for _, part := range splitTemplate(strings.Trim(s, "/")) {
+		if part == "" {
+			return nil, fmt.Errorf("invalid target path template %q", s)
+		}
+
+		if m := variableRegexp.FindStringSubmatch(part); m != nil {
+			t.segments = append(t.segments, &segment{variable: m[1], width: variableWidth(m[1])})
+			continue
+		}
+
+		if m := actionRegexp.FindStringSubmatch(part); m != nil {
+			seg, err := parseAction(m[1])
+			if err != nil {
+				return nil, fmt.Errorf("target path template %q: %v", s, err)
+			}
+			t.segments = append(t.segments, seg)
+			continue
+		}
+
+		if strings.Contains(part, "$") || strings.Contains(part, "{{") {
+			return nil, fmt.Errorf("target path template %q: variables must be whole segments", s)
+		}
+
+		t.segments = append(t.segments, &segment{literal: part, width: 1})
+	}
+
+	return t, nil
+}
+
+// parseAction makes a segment of an action like "{{ .Branch | slugify }}"
+// or "{{ date "2006/01/02" }}", where functions other than slugify only
+// change the value and not how many segments it spans
+func parseAction(action string) (*segment, error) {
+	commands := strings.Split(action, "|")
+	first := strings.TrimSpace(commands[0])
+
+	if m := dateRegexp.FindStringSubmatch(first); m != nil {
+		return &segment{variable: "DATE", width: strings.Count(m[1], "/") + 1}, nil
+	}
+
+	m := fieldRegexp.FindStringSubmatch(first)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported action {{ %s }}", action)
+	}
+
+	variable, ok := fieldVariables[m[1]]
+	if !ok {
+		return nil, fmt.Errorf("unknown field .%s", m[1])
+	}
+
+	seg := &segment{variable: variable, width: variableWidth(variable)}
+	for _, command := range commands[1:] {
+		if strings.TrimSpace(command) == "slugify" {
+			seg.width = 1
+		}
+	}
+
+	return seg, nil
+}
+
+// splitTemplate splits on the slashes that are not inside an action
+func splitTemplate(s string) []string {
+	parts := []string{}
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "{{"):
+			depth++
+			i++
+		case strings.HasPrefix(s[i:], "}}") && depth > 0:
+			depth--
+			i++
+		case s[i] == '/' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+func variableWidth(name string) int {
+	if strings.Contains(name, "REPO_SLUG") {
+		return 2
+	}
+	return 1
+}
+
+// Prefix is the literal part of the template before any variables,
+// which is all that needs listing
+func (t *template) Prefix() string {
+	literals := []string{}
+	for _, seg := range t.segments {
+		if seg.variable != "" {
+			break
+		}
+		literals = append(literals, seg.literal)
+	}
+
+	if len(literals) == 0 {
+		return ""
+	}
+
+	return strings.Join(literals, "/") + "/"
+}
+
+// HasBuild tells if the template has a build number or id segment
+func (t *template) HasBuild() bool {
+	for _, seg := range t.segments {
+		if isBuildVariable(seg.variable) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match tells which group and build the key belongs to, if it is under
+// the template at all
+func (t *template) Match(key string) (*match, bool) {
+	parts := strings.Split(key, "/")
+	groups := []string{}
+	m := &match{}
+	i := 0
+
+	for _, seg := range t.segments {
+		n := seg.width
+
+		// there must be at least one segment left over for the file
+		if i+n >= len(parts) {
+			return nil, false
+		}
+
+		value := strings.Join(parts[i:i+n], "/")
+		i += n
+
+		switch {
+		case seg.variable == "":
+			if value != seg.literal {
+				return nil, false
+			}
+		case isBuildVariable(seg.variable):
+			m.Build = value
+		case strings.Contains(seg.variable, "REPO_SLUG"), strings.Contains(seg.variable, "BRANCH"):
+			groups = append(groups, value)
+		}
+	}
+
+	m.Group = strings.Join(groups, " ")
+	return m, true
+}
+
+func isBuildVariable(name string) bool {
+	return strings.Contains(name, "BUILD_NUMBER") || strings.Contains(name, "BUILD_ID")
+}
@@ -0,0 +1,191 @@
+package remote
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mitchellh/goamz/s3"
+)
+
+const (
+	allUsersURI           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	authenticatedUsersURI = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+var (
+	// copiedHeaders are the headers of an object kept by a copy, besides
+	// the x-amz-meta-* ones
+	copiedHeaders = []string{
+		"Cache-Control",
+		"Content-Disposition",
+		"Content-Encoding",
+		"Content-Language",
+		"Content-Type",
+		"Expires",
+		"X-Amz-Website-Redirect-Location",
+	}
+)
+
+type accessControlPolicy struct {
+	Grants []struct {
+		URI        string `xml:"Grantee>URI"`
+		Permission string
+	} `xml:"AccessControlList>Grant"`
+}
+
+type copyResult struct {
+	ETag string
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// Head returns the headers of the key that a copy should keep
+func Head(b *s3.Bucket, key string) (http.Header, error) {
+	resp, _, err := Do(b, "HEAD", key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := http.Header{}
+	for name, values := range resp.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+			headers[name] = values
+		}
+	}
+
+	for _, name := range copiedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			headers.Set(name, value)
+		}
+	}
+
+	return headers, nil
+}
+
+// ACL returns the canned ACL closest to the key's grants, since a copy
+// does not keep them
+func ACL(b *s3.Bucket, key string) (s3.ACL, error) {
+	_, body, err := Do(b, "GET", key, url.Values{"acl": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	policy := &accessControlPolicy{}
+	err = xml.Unmarshal(body, policy)
+	if err != nil {
+		return "", err
+	}
+
+	granted := map[string]bool{}
+	for _, g := range policy.Grants {
+		granted[g.URI+" "+g.Permission] = true
+	}
+
+	switch {
+	case granted[allUsersURI+" READ"] && granted[allUsersURI+" WRITE"]:
+		return s3.PublicReadWrite, nil
+	case granted[allUsersURI+" READ"]:
+		return s3.PublicRead, nil
+	case granted[authenticatedUsersURI+" READ"]:
+		return s3.AuthenticatedRead, nil
+	}
+
+	return s3.Private, nil
+}
+
+// Copy copies the key server-side, replacing its headers with those
+// given.  Keys bigger than partSize are copied in parts of that size.
+func Copy(b *s3.Bucket, src, dest string, size, partSize int64, headers http.Header, perm s3.ACL) error {
+	headers = cloneHeaders(headers)
+	headers.Set("X-Amz-Acl", string(perm))
+
+	if size > partSize {
+		return multipartCopy(b, src, dest, size, partSize, headers)
+	}
+
+	headers.Set("X-Amz-Copy-Source", copySource(b, src))
+	headers.Set("X-Amz-Metadata-Directive", "REPLACE")
+
+	_, body, err := Do(b, "PUT", dest, nil, headers)
+	if err != nil {
+		return err
+	}
+
+	return xml.Unmarshal(body, &copyResult{})
+}
+
+// InitMulti starts a multipart upload with all of the headers given, as
+// goamz only sets the content type and ACL
+func InitMulti(b *s3.Bucket, key string, headers http.Header) (*s3.Multi, error) {
+	_, body, err := Do(b, "POST", key, url.Values{"uploads": {""}}, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &initiateMultipartUploadResult{}
+	err = xml.Unmarshal(body, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.Multi{Bucket: b, Key: key, UploadId: result.UploadID}, nil
+}
+
+func multipartCopy(b *s3.Bucket, src, dest string, size, partSize int64, headers http.Header) error {
+	multi, err := InitMulti(b, dest, headers)
+	if err != nil {
+		return err
+	}
+
+	parts := []s3.Part{}
+
+	for n, start := 1, int64(0); start < size; n, start = n+1, start+partSize {
+		end := start + partSize - 1
+		if end >= size {
+			end = size - 1
+		}
+
+		_, body, err := Do(b, "PUT", dest, url.Values{
+			"partNumber": {fmt.Sprintf("%d", n)},
+			"uploadId":   {multi.UploadId},
+		}, http.Header{
+			"X-Amz-Copy-Source":       {copySource(b, src)},
+			"X-Amz-Copy-Source-Range": {fmt.Sprintf("bytes=%d-%d", start, end)},
+		})
+
+		part := &copyResult{}
+		if err == nil {
+			err = xml.Unmarshal(body, part)
+		}
+
+		if err != nil {
+			multi.Abort()
+			return err
+		}
+
+		parts = append(parts, s3.Part{N: n, ETag: part.ETag, Size: end - start + 1})
+	}
+
+	err = multi.Complete(parts)
+	if err != nil {
+		multi.Abort()
+	}
+	return err
+}
+
+func copySource(b *s3.Bucket, key string) string {
+	return "/" + b.Name + "/" + escapeKey(key)
+}
+
+func cloneHeaders(headers http.Header) http.Header {
+	clone := http.Header{}
+	for name, values := range headers {
+		clone[name] = append([]string{}, values...)
+	}
+	return clone
+}
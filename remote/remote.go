@@ -0,0 +1,137 @@
+package remote
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/env"
+)
+
+const (
+	deleteBatchSize = 1000
+)
+
+// Options are those needed to get at a bucket, for the commands that
+// work on what is already uploaded
+type Options struct {
+	AccessKey  string
+	BucketName string
+	SecretKey  string
+	S3Region   string
+}
+
+// NewOptions makes some *Options with defaults from the env, using the
+// same variables as the upload command
+func NewOptions() Options {
+	opts := Options{}
+	opts.AccessKey, _ = env.CascadeMatch([]string{
+		"ARTIFACTS_KEY", "ARTIFACTS_AWS_ACCESS_KEY", "AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY"}, "")
+	opts.SecretKey, _ = env.CascadeMatch([]string{
+		"ARTIFACTS_SECRET", "ARTIFACTS_AWS_SECRET_KEY", "AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY"}, "")
+	opts.BucketName, _ = env.CascadeMatch([]string{"ARTIFACTS_BUCKET", "ARTIFACTS_S3_BUCKET"}, "")
+	opts.S3Region, _ = env.CascadeMatch([]string{"ARTIFACTS_REGION", "ARTIFACTS_S3_REGION"}, "us-east-1")
+	return opts
+}
+
+// Flags returns the command line flags for the bucket options
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{Name: "key, k", Usage: "credentials key"},
+		cli.StringFlag{Name: "secret, s", Usage: "credentials secret"},
+		cli.StringFlag{Name: "bucket, b", Usage: "bucket *REQUIRED*"},
+		cli.StringFlag{Name: "s3-region", Usage: "region of the bucket"},
+	}
+}
+
+// UpdateFromCLI overlays a *cli.Context onto the options
+func (opts *Options) UpdateFromCLI(c *cli.Context) {
+	for name, field := range map[string]*string{
+		"key":       &opts.AccessKey,
+		"secret":    &opts.SecretKey,
+		"bucket":    &opts.BucketName,
+		"s3-region": &opts.S3Region,
+	} {
+		if value := strings.TrimSpace(c.String(name)); value != "" {
+			*field = value
+		}
+	}
+}
+
+// Validate checks for validity!
+func (opts *Options) Validate() error {
+	if opts.BucketName == "" {
+		return fmt.Errorf("no bucket name given")
+	}
+
+	if _, ok := aws.Regions[opts.S3Region]; !ok {
+		return fmt.Errorf("invalid region %q", opts.S3Region)
+	}
+
+	return nil
+}
+
+// Secrets returns the credentials, for redacting them from the logs
+func (opts *Options) Secrets() []string {
+	return []string{opts.AccessKey, opts.SecretKey}
+}
+
+// Bucket connects to the bucket
+func (opts *Options) Bucket() (*s3.Bucket, error) {
+	auth, err := aws.GetAuth(opts.AccessKey, opts.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.New(auth, aws.Regions[opts.S3Region]).Bucket(opts.BucketName), nil
+}
+
+// Keys lists every key under the prefix, a page at a time
+func Keys(b *s3.Bucket, prefix string) ([]s3.Key, error) {
+	keys := []s3.Key{}
+	marker := ""
+
+	for {
+		resp, err := b.List(prefix, "", marker, 1000)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, k := range resp.Contents {
+			keys = append(keys, k)
+			marker = k.Key
+		}
+
+		if !resp.IsTruncated {
+			return keys, nil
+		}
+
+		if resp.NextMarker != "" {
+			marker = resp.NextMarker
+		}
+	}
+}
+
+// Delete deletes the keys in batches as big as DeleteObjects allows
+func Delete(b *s3.Bucket, keys []string) error {
+	for start := 0; start < len(keys); start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		objects := []s3.Object{}
+		for _, key := range keys[start:end] {
+			objects = append(objects, s3.Object{Key: key})
+		}
+
+		err := b.DelMulti(s3.Delete{Quiet: true, Objects: objects})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
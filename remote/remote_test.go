@@ -0,0 +1,67 @@
+package remote
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func TestNewOptions(t *testing.T) {
+	os.Setenv("ARTIFACTS_S3_BUCKET", "foo")
+	os.Setenv("ARTIFACTS_REGION", "us-west-2")
+	defer os.Unsetenv("ARTIFACTS_S3_BUCKET")
+	defer os.Unsetenv("ARTIFACTS_REGION")
+
+	opts := NewOptions()
+	if opts.BucketName != "foo" {
+		t.Fatalf("%v != foo", opts.BucketName)
+	}
+
+	if opts.S3Region != "us-west-2" {
+		t.Fatalf("%v != us-west-2", opts.S3Region)
+	}
+
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	opts := &Options{S3Region: "us-east-1"}
+	if opts.Validate() == nil {
+		t.Fatalf("missing bucket was valid")
+	}
+
+	opts = &Options{BucketName: "foo", S3Region: "mars-1"}
+	if opts.Validate() == nil {
+		t.Fatalf("invalid region was valid")
+	}
+}
+
+func TestStringToSign(t *testing.T) {
+	headers := http.Header{
+		"Date":                    {"Tue, 27 Mar 2007 21:20:26 +0000"},
+		"Content-Type":            {"text/plain"},
+		"X-Amz-Copy-Source":       {"/bucket/a.txt"},
+		"X-Amz-Copy-Source-Range": {"bytes=0-9"},
+	}
+
+	s := stringToSign("PUT", "/bucket/b.txt", url.Values{
+		"uploadId":   {"abc"},
+		"partNumber": {"1"},
+		"versionId":  {"unsigned"},
+	}, headers)
+
+	expected := "PUT\n\ntext/plain\nTue, 27 Mar 2007 21:20:26 +0000\n" +
+		"x-amz-copy-source:/bucket/a.txt\nx-amz-copy-source-range:bytes=0-9\n" +
+		"/bucket/b.txt?partNumber=1&uploadId=abc"
+
+	if s != expected {
+		t.Fatalf("%q != %q", s, expected)
+	}
+
+	if encodeParams(url.Values{"uploads": {""}}) != "uploads" {
+		t.Fatalf("empty value was not left out")
+	}
+}
@@ -0,0 +1,178 @@
+package remote
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/goamz/s3"
+)
+
+var (
+	// subresources are the query parameters that are signed
+	subresources = map[string]bool{
+		"acl":        true,
+		"partNumber": true,
+		"uploadId":   true,
+		"uploads":    true,
+	}
+)
+
+// s3Error is the body of a failed S3 request, which for copies may come
+// with a 200 status
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string
+	Message string
+
+	method string
+	key    string
+	status int
+}
+
+func (e *s3Error) Error() string {
+	return fmt.Sprintf("%s %s: %s %s", e.method, e.key, e.Code, e.Message)
+}
+
+// IsNotFound tells whether a request failed because the key does not
+// exist
+func IsNotFound(err error) bool {
+	e, ok := err.(*s3Error)
+	return ok && e.status == http.StatusNotFound
+}
+
+// Do makes a signed request for one of the S3 calls goamz lacks, like
+// HEAD, getting an ACL or copying a part.  The response body is read
+// and closed, and returned along with the response.
+func Do(b *s3.Bucket, method, key string, params url.Values, headers http.Header) (*http.Response, []byte, error) {
+	resource := "/" + b.Name + "/" + escapeKey(key)
+
+	u, err := url.Parse(strings.TrimRight(b.Region.S3Endpoint, "/") + resource)
+	if err != nil {
+		return nil, nil, err
+	}
+	u.RawQuery = encodeParams(params)
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	if b.Auth.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", b.Auth.Token)
+	}
+
+	signature := sign(b.Auth.SecretKey, stringToSign(method, resource, params, req.Header))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS %s:%s", b.Auth.AccessKey, signature))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e := &s3Error{method: method, key: key, status: resp.StatusCode}
+	if resp.StatusCode >= 300 || (len(body) > 0 && xml.Unmarshal(body, e) == nil) {
+		if e.Code == "" {
+			e.Code = resp.Status
+		}
+		return nil, nil, e
+	}
+
+	return resp, body, nil
+}
+
+// stringToSign is the string signed for signature version 2
+func stringToSign(method, resource string, params url.Values, headers http.Header) string {
+	amzValues := map[string]string{}
+	amzNames := []string{}
+	for name, values := range headers {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") {
+			amzValues[name] = strings.Join(values, ",")
+			amzNames = append(amzNames, name)
+		}
+	}
+
+	// sorted by name, as sorting "name:value" would put
+	// x-amz-copy-source-range before x-amz-copy-source
+	sort.Strings(amzNames)
+	amz := []string{}
+	for _, name := range amzNames {
+		amz = append(amz, name+":"+amzValues[name])
+	}
+
+	signed := url.Values{}
+	for name, values := range params {
+		if subresources[name] {
+			signed[name] = values
+		}
+	}
+
+	if len(signed) > 0 {
+		resource += "?" + encodeParams(signed)
+	}
+
+	lines := []string{
+		method,
+		headers.Get("Content-MD5"),
+		headers.Get("Content-Type"),
+		headers.Get("Date"),
+	}
+	lines = append(lines, amz...)
+	lines = append(lines, resource)
+
+	return strings.Join(lines, "\n")
+}
+
+func sign(secret, s string) string {
+	h := hmac.New(sha1.New, []byte(secret))
+	h.Write([]byte(s))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// encodeParams sorts by name and leaves out the "=" of empty values,
+// as in "?uploads"
+func encodeParams(params url.Values) string {
+	names := []string{}
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := []string{}
+	for _, name := range names {
+		for _, value := range params[name] {
+			if value == "" {
+				parts = append(parts, url.QueryEscape(name))
+				continue
+			}
+			parts = append(parts, url.QueryEscape(name)+"="+url.QueryEscape(value))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func escapeKey(key string) string {
+	return (&url.URL{Path: key}).EscapedPath()
+}
@@ -0,0 +1,187 @@
+// Package signing makes and checks detached minisign signatures, which
+// is an ed25519 signature of the file's BLAKE2b-512 hash plus a signed
+// trusted comment
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// Ext is appended to an artifact's destination for its signature
+	Ext = ".sig"
+
+	untrustedPrefix = "untrusted comment: "
+	trustedPrefix   = "trusted comment: "
+
+	// keys are always marked with the ed25519 algorithm
+	keyAlg = "Ed"
+	// signatures use the prehashed algorithm, so that files are hashed
+	// as they stream rather than held in memory.  minisign 0.8 and
+	// later verify it, and sign with it by default since 0.10.
+	sigAlg = "ED"
+
+	// sig alg, kdf alg, checksum alg, salt, opslimit, memlimit, key
+	// id, secret key and checksum
+	secretKeyLen = 2 + 2 + 2 + 32 + 8 + 8 + 8 + ed25519.PrivateKeySize + 32
+	publicKeyLen = 2 + 8 + ed25519.PublicKeySize
+	signatureLen = 2 + 8 + ed25519.SignatureSize
+)
+
+// Signer signs with a minisign secret key
+type Signer struct {
+	keyID []byte
+	key   ed25519.PrivateKey
+}
+
+// Verifier checks signatures against a minisign public key
+type Verifier struct {
+	keyID []byte
+	key   ed25519.PublicKey
+}
+
+// NewSigner loads an unencrypted minisign secret key, from a file or
+// given as the key itself
+func NewSigner(key string) (*Signer, error) {
+	b, err := decodeKey(key, secretKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(b[2:4]) != "\x00\x00" {
+		return nil, fmt.Errorf("encrypted secret keys are not supported, create one with minisign -G -W")
+	}
+
+	sk := ed25519.PrivateKey(b[62:126])
+	if !bytes.Equal(ed25519.NewKeyFromSeed(sk.Seed()), sk) {
+		return nil, fmt.Errorf("corrupt secret key")
+	}
+
+	return &Signer{keyID: b[54:62], key: sk}, nil
+}
+
+// NewVerifier loads a minisign public key, from a file or given as the
+// key itself
+func NewVerifier(key string) (*Verifier, error) {
+	b, err := decodeKey(key, publicKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verifier{keyID: b[2:10], key: ed25519.PublicKey(b[10:])}, nil
+}
+
+// NewHash returns the hash that messages are signed by, for checking
+// a signature with VerifyDigest while the message streams elsewhere
+func NewHash() hash.Hash {
+	return newBLAKE2b512()
+}
+
+// Sign returns the signature file for the message read from r, with
+// trustedComment signed along with it
+func (s *Signer) Sign(r io.Reader, trustedComment string) ([]byte, error) {
+	h := NewHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+
+	return s.signDigest(h.Sum(nil), trustedComment), nil
+}
+
+func (s *Signer) signDigest(digest []byte, trustedComment string) []byte {
+	sig := ed25519.Sign(s.key, digest)
+	global := ed25519.Sign(s.key, append(append([]byte{}, sig...), trustedComment...))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%ssignature from artifacts secret key\n", untrustedPrefix)
+	fmt.Fprintf(&buf, "%s\n", base64.StdEncoding.EncodeToString(
+		append(append([]byte(sigAlg), s.keyID...), sig...)))
+	fmt.Fprintf(&buf, "%s%s\n", trustedPrefix, trustedComment)
+	fmt.Fprintf(&buf, "%s\n", base64.StdEncoding.EncodeToString(global))
+	return buf.Bytes()
+}
+
+// Verify checks the signature file against the message read from r,
+// returning the trusted comment if it is good
+func (v *Verifier) Verify(r io.Reader, signature []byte) (string, error) {
+	h := NewHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+
+	return v.VerifyDigest(h.Sum(nil), signature)
+}
+
+// VerifyDigest checks the signature file against the message's digest
+// from NewHash, returning the trusted comment if it is good
+func (v *Verifier) VerifyDigest(digest, signature []byte) (string, error) {
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], trustedPrefix) {
+		return "", fmt.Errorf("malformed signature")
+	}
+
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(b) != signatureLen {
+		return "", fmt.Errorf("malformed signature")
+	}
+
+	if string(b[:2]) != sigAlg {
+		return "", fmt.Errorf("unsupported signature algorithm %q", b[:2])
+	}
+
+	if !bytes.Equal(b[2:10], v.keyID) {
+		return "", fmt.Errorf("signed with another key (%X)", reverse(b[2:10]))
+	}
+
+	sig := b[10:]
+	if !ed25519.Verify(v.key, digest, sig) {
+		return "", fmt.Errorf("signature verification failed")
+	}
+
+	trustedComment := strings.TrimSuffix(strings.TrimPrefix(lines[2], trustedPrefix), "\r")
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || !ed25519.Verify(v.key, append(append([]byte{}, sig...), trustedComment...), global) {
+		return "", fmt.Errorf("trusted comment verification failed")
+	}
+
+	return trustedComment, nil
+}
+
+// decodeKey reads the key file if there is one, then decodes the key
+// line, skipping the untrusted comment
+func decodeKey(key string, length int) ([]byte, error) {
+	if b, err := ioutil.ReadFile(key); err == nil {
+		key = string(b)
+	}
+
+	for _, line := range strings.Split(key, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, untrustedPrefix) {
+			continue
+		}
+
+		b, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(b) != length || string(b[:2]) != keyAlg {
+			break
+		}
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("not a minisign key or key file")
+}
+
+// reverse gives the key id in the order minisign prints it
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
@@ -0,0 +1,175 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testKeys makes an unencrypted minisign key pair, as from minisign -G -W
+func testKeys(t *testing.T, seed byte) (string, string) {
+	pub, sk, err := ed25519.GenerateKey(strings.NewReader(strings.Repeat(string(rune(seed)), 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyID := []byte{seed, 1, 2, 3, 4, 5, 6, 7}
+
+	secret := []byte("Ed\x00\x00B2")
+	secret = append(secret, make([]byte, 32+8+8)...)
+	secret = append(secret, keyID...)
+	secret = append(secret, sk...)
+	secret = append(secret, make([]byte, 32)...)
+
+	public := append(append([]byte("Ed"), keyID...), pub...)
+
+	return "untrusted comment: minisign encrypted secret key\n" + base64.StdEncoding.EncodeToString(secret) + "\n",
+		"untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(public) + "\n"
+}
+
+func TestSignAndVerify(t *testing.T) {
+	secret, public := testKeys(t, 'a')
+
+	signer, err := NewSigner(secret)
+	if err != nil {
+		t.Fatalf("failed to load secret key: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "artifacts-signing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "minisign.pub")
+	err = ioutil.WriteFile(keyFile, []byte(public), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := NewVerifier(keyFile)
+	if err != nil {
+		t.Fatalf("failed to load public key file: %v", err)
+	}
+
+	sig, err := signer.Sign(strings.NewReader("hello"), "file:hello.txt")
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	if !strings.HasPrefix(string(sig), "untrusted comment: ") {
+		t.Fatalf("unexpected signature %q", string(sig))
+	}
+
+	comment, err := verifier.Verify(strings.NewReader("hello"), sig)
+	if err != nil {
+		t.Fatalf("good signature failed to verify: %v", err)
+	}
+
+	if comment != "file:hello.txt" {
+		t.Fatalf("%v != file:hello.txt", comment)
+	}
+
+	if _, err := verifier.Verify(strings.NewReader("hellO"), sig); err == nil {
+		t.Fatalf("signature verified for changed message")
+	}
+
+	forged := strings.Replace(string(sig), "file:hello.txt", "file:other.txt", 1)
+	if _, err := verifier.Verify(strings.NewReader("hello"), []byte(forged)); err == nil {
+		t.Fatalf("signature verified with changed trusted comment")
+	}
+
+	_, otherPublic := testKeys(t, 'b')
+	other, err := NewVerifier(otherPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := other.Verify(strings.NewReader("hello"), sig); err == nil {
+		t.Fatalf("signature verified with another key")
+	}
+}
+
+func TestSignaturesArePrehashed(t *testing.T) {
+	secret, _ := testKeys(t, 'a')
+
+	signer, err := NewSigner(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := signer.Sign(strings.NewReader("hello"), "file:hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(strings.Split(string(sig), "\n")[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b[:2]) != "ED" {
+		t.Fatalf("%q != ED", b[:2])
+	}
+}
+
+func TestBLAKE2b512(t *testing.T) {
+	long := []byte{}
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 256; j++ {
+			long = append(long, byte(j))
+		}
+	}
+
+	for _, tc := range []struct {
+		message  []byte
+		expected string
+	}{
+		{[]byte(""), "786a02f742015903c6c6fd852552d272912f4740e15847618a86e217f71f5419d25e1031afee585313896444934eb04b903a685b1448b755d56f701afe9be2ce"},
+		{[]byte("abc"), "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+		{[]byte(strings.Repeat("a", 128)), "fc6c71f688f43ea7d60817478808f3cac753e61571865c95adbc2d9122c943a76b92c2cb1047ef3fe7bf6e436ec1d0a99a9e5b216780bf7fed9d7ca91d3a8f3b"},
+		{[]byte(strings.Repeat("a", 129)), "55e6e0eb418149a8af92fd9ddc99254781b2f522a131b4f4d984404b71a00e1167b8124d5dcddd4c6977b299392335d6edd303da6d344d74bbef2d38101b232b"},
+		{long, "a86b784c748f990b998e6d30d71e20cc95228d2b08dd85e29f63e4de8d8839bdf935f4291537af5014fe44c0b578a073e4c9217c7b05542d0c450784c30bac8a"},
+	} {
+		h := NewHash()
+		// in uneven writes, to cross the block boundaries
+		for i := 0; i < len(tc.message); i += 100 {
+			end := i + 100
+			if end > len(tc.message) {
+				end = len(tc.message)
+			}
+			h.Write(tc.message[i:end])
+		}
+
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != tc.expected {
+			t.Fatalf("%d bytes: %v != %v", len(tc.message), sum, tc.expected)
+		}
+	}
+}
+
+func TestNewSignerInvalid(t *testing.T) {
+	secret, public := testKeys(t, 'a')
+
+	b, err := base64.StdEncoding.DecodeString(strings.Split(secret, "\n")[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(b[2:4], "Sc")
+	encrypted := base64.StdEncoding.EncodeToString(b)
+
+	for _, key := range []string{
+		"",
+		"does-not-exist.key",
+		public,
+		encrypted,
+	} {
+		if _, err := NewSigner(key); err == nil {
+			t.Fatalf("invalid key %q was loaded", key)
+		}
+	}
+}
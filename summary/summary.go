@@ -0,0 +1,115 @@
+package summary
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// FailurePolicyIgnore never fails the run because of failed artifacts
+	FailurePolicyIgnore = "ignore"
+	// FailurePolicyAny fails the run if any artifact failed on any
+	// destination
+	FailurePolicyAny = "any"
+	// FailurePolicyAll fails the run if any artifact failed on every
+	// destination
+	FailurePolicyAll = "all"
+)
+
+var (
+	// FailurePolicies are the valid failure policies
+	FailurePolicies = map[string]bool{
+		FailurePolicyIgnore: true,
+		FailurePolicyAny:    true,
+		FailurePolicyAll:    true,
+	}
+)
+
+type destination struct {
+	Done    uint64
+	Failed  uint64
+	Retries uint64
+}
+
+// Summary tallies results per destination provider, for the commands
+// that move artifacts around
+type Summary struct {
+	action       string
+	done         string
+	providers    []string
+	destinations map[string]*destination
+	failures     map[string]int
+}
+
+// New makes a *Summary for the action, e.g. "upload", which is logged
+// with the count of artifacts done, e.g. "uploaded"
+func New(action, done string, providers []string) *Summary {
+	s := &Summary{
+		action:       action,
+		done:         done,
+		providers:    providers,
+		destinations: map[string]*destination{},
+		failures:     map[string]int{},
+	}
+
+	for _, name := range providers {
+		s.destinations[name] = &destination{}
+	}
+
+	return s
+}
+
+// Record tallies the result for an artifact, named e.g. "source -> dest"
+func (s *Summary) Record(provider, name string, ok bool, retries uint64) {
+	d, exists := s.destinations[provider]
+	if !exists {
+		d = &destination{}
+		s.destinations[provider] = d
+	}
+
+	d.Retries += retries
+	if ok {
+		d.Done++
+		return
+	}
+
+	d.Failed++
+	s.failures[name]++
+}
+
+// Log logs a line per destination provider
+func (s *Summary) Log(log *logrus.Logger) {
+	for _, name := range s.providers {
+		d := s.destinations[name]
+		log.WithFields(logrus.Fields{
+			"provider": name,
+			s.done:     d.Done,
+			"failed":   d.Failed,
+			"retries":  d.Retries,
+		}).Info(fmt.Sprintf("%s summary", s.action))
+	}
+}
+
+// Err returns an error if the failures recorded are not acceptable
+// according to the failure policy given
+func (s *Summary) Err(policy string) error {
+	failed := 0
+
+	switch policy {
+	case FailurePolicyAny:
+		failed = len(s.failures)
+	case FailurePolicyAll:
+		for _, n := range s.failures {
+			if n >= len(s.providers) {
+				failed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d artifacts failed to %s", failed, s.action)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package summary
+
+import (
+	"testing"
+)
+
+func TestSummaryErr(t *testing.T) {
+	s := New("upload", "uploaded", []string{"s3", "artifacts"})
+	s.Record("s3", "a.txt -> a.txt", false, 2)
+	s.Record("artifacts", "a.txt -> a.txt", true, 0)
+	s.Record("s3", "b.txt -> b.txt", true, 0)
+
+	for policy, fails := range map[string]bool{
+		FailurePolicyIgnore: false,
+		FailurePolicyAll:    false,
+		FailurePolicyAny:    true,
+	} {
+		err := s.Err(policy)
+		if (err != nil) != fails {
+			t.Fatalf("%s: unexpected error %v", policy, err)
+		}
+	}
+
+	s.Record("artifacts", "a.txt -> a.txt", false, 0)
+	if s.Err(FailurePolicyAll) == nil {
+		t.Fatalf("artifact failed everywhere did not fail the run")
+	}
+
+	if s.destinations["s3"].Retries != 2 {
+		t.Fatalf("%v != 2", s.destinations["s3"].Retries)
+	}
+}
@@ -0,0 +1,372 @@
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	instrumentationName = "github.com/travis-ci/artifacts"
+
+	statusOK    = 1
+	statusError = 2
+
+	kindInternal = 1
+)
+
+var (
+	errInvalidTraceparent = fmt.Errorf("invalid traceparent")
+
+	// exportClient gives up on a collector that doesn't answer, so a
+	// dead one fails the flush instead of hanging the end of the run
+	exportClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Tracer collects spans and exports them via OTLP/HTTP using the JSON
+// encoding.  A nil *Tracer is valid and records nothing, as are the
+// nil *Span values it hands out.
+type Tracer struct {
+	sync.Mutex
+
+	Endpoint    string
+	ServiceName string
+
+	traceID      string
+	remoteParent string
+	root         *Span
+	finished     []*Span
+}
+
+// Span is a single timed operation
+type Span struct {
+	sync.Mutex
+
+	tracer   *Tracer
+	name     string
+	traceID  string
+	spanID   string
+	parentID string
+	start    time.Time
+	end      time.Time
+	attrs    map[string]interface{}
+	status   int
+	message  string
+}
+
+// New creates a *Tracer that exports to the OTLP/HTTP endpoint given,
+// e.g. "http://localhost:4318".  If traceparent is a valid W3C trace
+// context header value, all spans join that trace.
+func New(endpoint, serviceName, traceparent string) (*Tracer, error) {
+	t := &Tracer{
+		Endpoint:    strings.TrimRight(endpoint, "/"),
+		ServiceName: serviceName,
+		finished:    []*Span{},
+	}
+
+	if traceparent != "" {
+		traceID, parentID, err := ParseTraceparent(traceparent)
+		if err != nil {
+			t.traceID = randomHex(16)
+			return t, err
+		}
+		t.traceID = traceID
+		t.remoteParent = parentID
+		return t, nil
+	}
+
+	t.traceID = randomHex(16)
+	return t, nil
+}
+
+// ParseTraceparent returns the trace id and parent span id from a W3C
+// trace context traceparent header value
+func ParseTraceparent(traceparent string) (string, string, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", "", errInvalidTraceparent
+	}
+
+	for _, part := range parts[:4] {
+		if _, err := hex.DecodeString(part); err != nil {
+			return "", "", errInvalidTraceparent
+		}
+	}
+
+	if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return "", "", errInvalidTraceparent
+	}
+
+	return strings.ToLower(parts[1]), strings.ToLower(parts[2]), nil
+}
+
+// StartRoot starts the span that spans started without a parent will
+// belong to
+func (t *Tracer) StartRoot(name string) *Span {
+	if t == nil {
+		return nil
+	}
+
+	s := t.newSpan(name, t.remoteParent)
+
+	t.Lock()
+	t.root = s
+	t.Unlock()
+
+	return s
+}
+
+// Start starts a new span as a child of parent, or of the root span if
+// parent is nil
+func (t *Tracer) Start(name string, parent *Span) *Span {
+	if t == nil {
+		return nil
+	}
+
+	parentID := t.remoteParent
+	if parent == nil {
+		t.Lock()
+		parent = t.root
+		t.Unlock()
+	}
+
+	if parent != nil {
+		parentID = parent.spanID
+	}
+
+	return t.newSpan(name, parentID)
+}
+
+func (t *Tracer) newSpan(name, parentID string) *Span {
+	return &Span{
+		tracer:   t,
+		name:     name,
+		traceID:  t.traceID,
+		spanID:   randomHex(8),
+		parentID: parentID,
+		start:    time.Now(),
+		attrs:    map[string]interface{}{},
+	}
+}
+
+// Traceparent returns the W3C trace context header value for the span
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", s.traceID, s.spanID)
+}
+
+// SetAttribute sets a string, integer, float or boolean attribute
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.attrs[key] = value
+}
+
+// SetError marks the span as failed with the given error, or as
+// successful when err is nil
+func (s *Span) SetError(err error) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if err == nil {
+		s.status = statusOK
+		s.message = ""
+		return
+	}
+
+	s.status = statusError
+	s.message = err.Error()
+}
+
+// End finishes the span so that it is exported on the next Flush
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	if !s.end.IsZero() {
+		s.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.Unlock()
+
+	s.tracer.Lock()
+	defer s.tracer.Unlock()
+	s.tracer.finished = append(s.tracer.finished, s)
+}
+
+// Flush exports all finished spans
+func (t *Tracer) Flush() error {
+	if t == nil {
+		return nil
+	}
+
+	t.Lock()
+	spans := t.finished
+	t.finished = []*Span{}
+	t.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(t.buildRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/v1/traces", t.Endpoint)
+	resp, err := exportClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to export spans to %s: %s", url, resp.Status)
+	}
+
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func (t *Tracer) buildRequest(spans []*Span) *otlpRequest {
+	otlpSpans := []otlpSpan{}
+	for _, s := range spans {
+		s.Lock()
+		otlpSpans = append(otlpSpans, otlpSpan{
+			TraceID:           s.traceID,
+			SpanID:            s.spanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              kindInternal,
+			StartTimeUnixNano: fmt.Sprintf("%d", s.start.UnixNano()),
+			EndTimeUnixNano:   fmt.Sprintf("%d", s.end.UnixNano()),
+			Attributes:        toKeyValues(s.attrs),
+			Status:            otlpStatus{Code: s.status, Message: s.message},
+		})
+		s.Unlock()
+	}
+
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{
+			otlpResourceSpans{
+				Resource: otlpResource{
+					Attributes: toKeyValues(map[string]interface{}{
+						"service.name": t.ServiceName,
+					}),
+				},
+				ScopeSpans: []otlpScopeSpans{
+					otlpScopeSpans{
+						Scope: otlpScope{Name: instrumentationName},
+						Spans: otlpSpans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func toKeyValues(attrs map[string]interface{}) []otlpKeyValue {
+	keys := []string{}
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := []otlpKeyValue{}
+	for _, k := range keys {
+		v := attrs[k]
+		var value map[string]interface{}
+
+		switch tv := v.(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": tv}
+		case int:
+			value = map[string]interface{}{"intValue": fmt.Sprintf("%d", tv)}
+		case int64:
+			value = map[string]interface{}{"intValue": fmt.Sprintf("%d", tv)}
+		case uint64:
+			value = map[string]interface{}{"intValue": fmt.Sprintf("%d", tv)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": tv}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprintf("%v", tv)}
+		}
+
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: value})
+	}
+	return kvs
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	traceID, parentID, err := ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	if err != nil {
+		t.Fatalf("valid traceparent failed to parse: %v", err)
+	}
+
+	if traceID != "0af7651916cd43dd8448eb211c80319c" {
+		t.Fatalf("trace id %v != 0af7651916cd43dd8448eb211c80319c", traceID)
+	}
+
+	if parentID != "b7ad6b7169203331" {
+		t.Fatalf("parent id %v != b7ad6b7169203331", parentID)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"nope",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-zzf7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	} {
+		if _, _, err := ParseTraceparent(invalid); err == nil {
+			t.Fatalf("invalid traceparent %q parsed", invalid)
+		}
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+
+	span := tracer.StartRoot("run")
+	span.SetAttribute("foo", "bar")
+	span.SetError(fmt.Errorf("nope"))
+	span.End()
+
+	if tracer.Start("child", span) != nil {
+		t.Fatalf("nil tracer started a span")
+	}
+
+	if tracer.Flush() != nil {
+		t.Fatalf("nil tracer failed to flush")
+	}
+}
+
+func TestTracerFlush(t *testing.T) {
+	var req otlpRequest
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &req)
+	}))
+	defer ts.Close()
+
+	tracer, err := New(ts.URL, "artifacts", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	if err != nil {
+		t.Fatalf("error creating tracer: %v", err)
+	}
+
+	root := tracer.StartRoot("run")
+	child := tracer.Start("walk", nil)
+	child.SetAttribute("size", uint64(42))
+	child.SetError(nil)
+	child.End()
+	root.End()
+	root.End()
+
+	err = tracer.Flush()
+	if err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request: %#v", req)
+	}
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("exported %v spans != 2", len(spans))
+	}
+
+	walk, run := spans[0], spans[1]
+	if run.ParentSpanID != "b7ad6b7169203331" {
+		t.Fatalf("root parent %v != b7ad6b7169203331", run.ParentSpanID)
+	}
+
+	if walk.ParentSpanID != run.SpanID {
+		t.Fatalf("child parent %v != %v", walk.ParentSpanID, run.SpanID)
+	}
+
+	if walk.TraceID != "0af7651916cd43dd8448eb211c80319c" {
+		t.Fatalf("trace id %v != 0af7651916cd43dd8448eb211c80319c", walk.TraceID)
+	}
+
+	if walk.Status.Code != statusOK {
+		t.Fatalf("status %v != %v", walk.Status.Code, statusOK)
+	}
+
+	if len(walk.Attributes) != 1 || walk.Attributes[0].Value["intValue"] != "42" {
+		t.Fatalf("unexpected attributes: %#v", walk.Attributes)
+	}
+}
+
+func TestTracerFlushTimeout(t *testing.T) {
+	block := make(chan bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer ts.Close()
+	defer close(block)
+
+	client := exportClient
+	exportClient = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() { exportClient = client }()
+
+	tracer, err := New(ts.URL, "artifacts", "")
+	if err != nil {
+		t.Fatalf("error creating tracer: %v", err)
+	}
+	tracer.StartRoot("run").End()
+
+	if tracer.Flush() == nil {
+		t.Fatalf("flush to a hanging endpoint did not error")
+	}
+}
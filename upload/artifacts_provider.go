@@ -7,6 +7,7 @@ import (
 	"github.com/dustin/go-humanize"
 	"github.com/travis-ci/artifacts/artifact"
 	"github.com/travis-ci/artifacts/client"
+	"github.com/travis-ci/artifacts/tracing"
 )
 
 var (
@@ -20,6 +21,8 @@ type artifactsProvider struct {
 	log  *logrus.Logger
 
 	overrideClient client.ArtifactPutter
+
+	tracer *tracing.Tracer
 }
 
 func newArtifactsProvider(opts *Options, log *logrus.Logger) *artifactsProvider {
@@ -37,13 +40,17 @@ func (ap *artifactsProvider) Upload(id string, opts *Options,
 	cl := ap.getClient()
 
 	for a := range in {
-		err := ap.uploadFile(cl, a)
+		span := startArtifactSpan(ap.tracer, ap.Name(), a)
+		start := time.Now()
+		err := ap.uploadFile(cl, a, span)
+		a.UploadResult.Duration = time.Since(start)
 		if err != nil {
 			a.UploadResult.OK = false
 			a.UploadResult.Err = err
 		} else {
 			a.UploadResult.OK = true
 		}
+		endArtifactSpan(span, a)
 		out <- a
 	}
 
@@ -51,16 +58,21 @@ func (ap *artifactsProvider) Upload(id string, opts *Options,
 	return
 }
 
-func (ap *artifactsProvider) uploadFile(cl client.ArtifactPutter, a *artifact.Artifact) error {
+func (ap *artifactsProvider) uploadFile(cl client.ArtifactPutter, a *artifact.Artifact, span *tracing.Span) error {
 	retries := uint64(0)
 
 	for {
-		err := ap.rawUpload(cl, a)
+		attempt := ap.tracer.Start("artifact.upload.attempt", span)
+		attempt.SetAttribute("attempt", retries+1)
+		err := ap.rawUpload(cl, a, attempt)
+		attempt.SetError(err)
+		attempt.End()
 		if err == nil {
 			return nil
 		}
-		if retries < ap.opts.Retries {
+		if retries < ap.opts.Retries && !a.Aborted() {
 			retries++
+			a.UploadResult.Retries = retries
 			ap.log.WithFields(logrus.Fields{
 				"artifact": a.Source,
 				"retry":    retries,
@@ -75,8 +87,8 @@ func (ap *artifactsProvider) uploadFile(cl client.ArtifactPutter, a *artifact.Ar
 	return nil
 }
 
-func (ap *artifactsProvider) rawUpload(cl client.ArtifactPutter, a *artifact.Artifact) error {
-	ctype := a.ContentType()
+func (ap *artifactsProvider) rawUpload(cl client.ArtifactPutter, a *artifact.Artifact, span *tracing.Span) error {
+	ctype := tracedContentType(ap.tracer, span, a)
 	size, err := a.Size()
 	if err != nil {
 		return err
@@ -88,7 +100,8 @@ func (ap *artifactsProvider) rawUpload(cl client.ArtifactPutter, a *artifact.Art
 		"source":           a.Source,
 		"dest":             a.FullDest(),
 		"content_type":     ctype,
-		"cache_control":    ap.opts.CacheControl,
+		"cache_control":    a.CacheControl,
+		"permissions":      a.Perm,
 	}).Debug("more artifact details")
 
 	return cl.PutArtifact(a)
@@ -0,0 +1,350 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/signing"
+)
+
+var (
+	checksumAlgorithms = map[string]func() hash.Hash{
+		"SHA256SUMS": sha256.New,
+		"SHA512SUMS": sha512.New,
+	}
+)
+
+// checksumSet collects the checksums of the artifacts of each target
+// path, hashing them as they are read for uploading.  A nil
+// *checksumSet is valid and collects nothing.
+type checksumSet struct {
+	sync.Mutex
+
+	names     []string
+	artifacts map[string]map[string]*artifactChecksums
+}
+
+type artifactChecksums struct {
+	source string
+	sums   map[string]string
+}
+
+func newChecksumSet(names []string) *checksumSet {
+	return &checksumSet{
+		names:     names,
+		artifacts: map[string]map[string]*artifactChecksums{},
+	}
+}
+
+// Add records the artifact, including one skipped as already uploaded,
+// and hashes it if it is read in full
+func (cs *checksumSet) Add(a *artifact.Artifact, size uint64) {
+	if cs == nil {
+		return
+	}
+
+	cs.Lock()
+	dests, ok := cs.artifacts[a.Prefix]
+	if !ok {
+		dests = map[string]*artifactChecksums{}
+		cs.artifacts[a.Prefix] = dests
+	}
+
+	dest := strings.TrimLeft(filepath.ToSlash(filepath.Clean(a.Dest)), "/")
+	ac, ok := dests[dest]
+	if !ok {
+		ac = &artifactChecksums{source: a.Source}
+		dests[dest] = ac
+	}
+	cs.Unlock()
+
+	a.AddReaderWrapper(func(r io.Reader) io.Reader {
+		return newChecksumReader(r, cs.names, func(sums map[string]string, n uint64) {
+			if n != size {
+				return
+			}
+
+			cs.Lock()
+			defer cs.Unlock()
+			if ac.sums == nil {
+				ac.sums = sums
+			}
+		})
+	})
+}
+
+// Sums returns the target path's artifacts in order with their
+// checksums, hashing any artifact that was never read in full
+func (cs *checksumSet) Sums(targetPath string) ([]string, map[string]map[string]string, error) {
+	cs.Lock()
+	defer cs.Unlock()
+
+	dests := []string{}
+	sums := map[string]map[string]string{}
+	for dest, ac := range cs.artifacts[targetPath] {
+		if ac.sums == nil {
+			fileSums, err := fileChecksums(ac.source, cs.names)
+			if err != nil {
+				return nil, nil, err
+			}
+			ac.sums = fileSums
+		}
+
+		dests = append(dests, dest)
+		sums[dest] = ac.sums
+	}
+	sort.Strings(dests)
+
+	return dests, sums, nil
+}
+
+// Files returns the content of the named checksum files for the target
+// path
+func (cs *checksumSet) Files(targetPath string, names []string) (map[string][]byte, error) {
+	dests, sums, err := cs.Sums(targetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	for _, name := range names {
+		var buf bytes.Buffer
+		for _, dest := range dests {
+			fmt.Fprintf(&buf, "%s  %s\n", sums[dest][name], dest)
+		}
+		files[name] = buf.Bytes()
+	}
+	return files, nil
+}
+
+// checksumReader hashes what is read through it, reporting the sums
+// once it reaches EOF
+type checksumReader struct {
+	r      io.Reader
+	hashes map[string]hash.Hash
+	n      uint64
+	done   func(map[string]string, uint64)
+}
+
+func newChecksumReader(r io.Reader, names []string, done func(map[string]string, uint64)) *checksumReader {
+	hashes := map[string]hash.Hash{}
+	for _, name := range names {
+		hashes[name] = checksumAlgorithms[name]()
+	}
+	return &checksumReader{r: r, hashes: hashes, done: done}
+}
+
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	if n > 0 {
+		cr.n += uint64(n)
+		for _, h := range cr.hashes {
+			h.Write(p[:n])
+		}
+	}
+
+	if err == io.EOF && cr.done != nil {
+		sums := map[string]string{}
+		for name, h := range cr.hashes {
+			sums[name] = hex.EncodeToString(h.Sum(nil))
+		}
+		cr.done(sums, cr.n)
+		cr.done = nil
+	}
+
+	return n, err
+}
+
+func fileChecksums(filename string, names []string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sums := map[string]string{}
+	cr := newChecksumReader(f, names, func(s map[string]string, _ uint64) { sums = s })
+	_, err = io.Copy(ioutil.Discard, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	return sums, nil
+}
+
+// checksumDests are the keys of the checksum files and their
+// signatures, if signing
+func (u *uploader) checksumDests() []string {
+	dests := []string{}
+	for _, targetPath := range u.Opts.TargetPaths {
+		for _, name := range u.Opts.Checksums {
+			dest := publishedDest(targetPath, name)
+			dests = append(dests, dest)
+			if u.Opts.SigningKey != "" {
+				dests = append(dests, dest+signing.Ext)
+			}
+		}
+	}
+	return dests
+}
+
+// publishChecksums uploads the checksum files of each target path with
+// every provider, once everything else is uploaded
+func (u *uploader) publishChecksums() error {
+	if len(u.Opts.Checksums) == 0 {
+		return nil
+	}
+
+	if u.incomplete {
+		u.log.Warn("not publishing checksum files after an incomplete walk")
+		return nil
+	}
+
+	for _, targetPath := range u.Opts.TargetPaths {
+		files, err := u.checksums.Files(targetPath, u.Opts.Checksums)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range u.Opts.Checksums {
+			err := u.publishSigned(targetPath, name, files[name], plainTextContentType)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// publishSigned publishes the file along with its signature, if signing
+func (u *uploader) publishSigned(targetPath, dest string, content []byte, contentType string) error {
+	err := u.publishFile(targetPath, dest, content, contentType)
+	if err != nil || u.signatures == nil {
+		return err
+	}
+
+	sig, err := u.signatures.signature(bytes.NewReader(content), dest)
+	if err != nil {
+		return err
+	}
+
+	return u.publishFile(targetPath, dest+signing.Ext, sig, plainTextContentType)
+}
+
+// publishedDest is the key publishFile uploads the named file to under
+// the target path, joined the same way as an artifact's FullDest
+func publishedDest(targetPath, name string) string {
+	return strings.TrimLeft(filepath.Join(targetPath, name), "/")
+}
+
+// publishFile uploads a file made during the upload, such as a checksum
+// file, to the target path with every provider
+func (u *uploader) publishFile(targetPath, dest string, content []byte, contentType string) error {
+	// kept until the end of the upload, as latest aliases are
+	// published from the files
+	if u.publishDir == "" {
+		dir, err := ioutil.TempDir("", "artifacts-publish")
+		if err != nil {
+			return err
+		}
+		u.publishDir = dir
+	}
+
+	source := filepath.Join(u.publishDir, fmt.Sprintf("%s-%s",
+		strings.Replace(targetPath, "/", "_", -1), path.Base(dest)))
+	err := ioutil.WriteFile(source, content, 0644)
+	if err != nil {
+		return err
+	}
+
+	publishOpts := &artifact.Options{
+		Perm:        s3.ACL(u.Opts.Perm),
+		RepoSlug:    u.Opts.RepoSlug,
+		BuildNumber: u.Opts.BuildNumber,
+		BuildID:     u.Opts.BuildID,
+		JobNumber:   u.Opts.JobNumber,
+		JobID:       u.Opts.JobID,
+
+		CacheControl: u.Opts.CacheControl,
+		ContentType:  contentType,
+	}
+
+	for _, provider := range u.Providers {
+		a := artifact.New(targetPath, source, dest, publishOpts)
+		a.UploadResult.Provider = provider.Name()
+
+		err := uploadOne(provider, u.Opts, a)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %v", a.FullDest(), err)
+		}
+
+		if provider.Name() == "s3" {
+			u.latest.Add(a)
+		}
+
+		u.log.WithFields(logrus.Fields{
+			"provider": provider.Name(),
+			"dest":     a.FullDest(),
+		}).Info(fmt.Sprintf("published: %s", dest))
+	}
+
+	return nil
+}
+
+// removePublished deletes the files made for publishing
+func (u *uploader) removePublished() {
+	if u.publishDir != "" {
+		os.RemoveAll(u.publishDir)
+	}
+}
+
+// uploadOne runs a single worker of the provider for just the artifact,
+// which only counts as uploaded if the worker says so
+func uploadOne(provider uploadProvider, opts *Options, a *artifact.Artifact) error {
+	in := make(chan *artifact.Artifact, 1)
+	out := make(chan *artifact.Artifact)
+	done := make(chan bool)
+
+	in <- a
+	close(in)
+	go provider.Upload(fmt.Sprintf("%s-publish", provider.Name()), opts, in, out, done)
+
+	uploaded := false
+	for {
+		select {
+		case outArtifact := <-out:
+			if outArtifact == nil {
+				continue
+			}
+			if !outArtifact.UploadResult.OK {
+				<-done
+				if outArtifact.UploadResult.Err == nil {
+					return errUploadFailed
+				}
+				return outArtifact.UploadResult.Err
+			}
+			uploaded = true
+		case <-done:
+			if !uploaded {
+				return errProviderStopped
+			}
+			return nil
+		}
+	}
+}
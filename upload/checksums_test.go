@@ -0,0 +1,192 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/signing"
+)
+
+func TestChecksumSetFiles(t *testing.T) {
+	cs := newChecksumSet([]string{"SHA256SUMS", "SHA512SUMS"})
+
+	source := filepath.Join(testArtifactPathDir, "foo")
+	content, err := ioutil.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+
+	streamed := artifact.New("artifacts/1", source, "/bin/foo", &artifact.Options{})
+	cs.Add(streamed, uint64(len(content)))
+
+	r, err := streamed.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(ioutil.Discard, r)
+
+	if cs.artifacts["artifacts/1"]["bin/foo"].sums["SHA256SUMS"] != hex.EncodeToString(sum[:]) {
+		t.Fatalf("checksum not recorded while reading: %v", cs.artifacts["artifacts/1"]["bin/foo"])
+	}
+
+	// skipped artifacts are never read, but still listed
+	cs.Add(artifact.New("artifacts/1", source, "a/foo", &artifact.Options{}), uint64(len(content)))
+	cs.Add(artifact.New("artifacts/2", source, "foo", &artifact.Options{}), uint64(len(content)))
+
+	files, err := cs.Files("artifacts/1", cs.names)
+	if err != nil {
+		t.Fatalf("failed to make checksum files: %v", err)
+	}
+
+	expected := hex.EncodeToString(sum[:]) + "  a/foo\n" + hex.EncodeToString(sum[:]) + "  bin/foo\n"
+	if string(files["SHA256SUMS"]) != expected {
+		t.Fatalf("%q != %q", string(files["SHA256SUMS"]), expected)
+	}
+
+	if strings.Count(string(files["SHA512SUMS"]), "\n") != 2 {
+		t.Fatalf("unexpected SHA512SUMS %q", string(files["SHA512SUMS"]))
+	}
+}
+
+func TestUploaderUploadChecksums(t *testing.T) {
+	opts := NewOptions()
+	opts.Paths = []string{testArtifactPathDir}
+	opts.TargetPaths = []string{"artifacts/1", "artifacts/latest"}
+	opts.Checksums = []string{"SHA256SUMS"}
+
+	ok := &recordingProvider{name: "ok"}
+	other := &recordingProvider{name: "other"}
+	u := newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{ok, other}
+
+	err := u.Upload()
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	for _, rp := range []*recordingProvider{ok, other} {
+		dests := map[string]bool{}
+		for _, a := range rp.uploaded {
+			dests[a.FullDest()] = true
+		}
+
+		for _, dest := range []string{"artifacts/1/SHA256SUMS", "artifacts/latest/SHA256SUMS"} {
+			if !dests[dest] {
+				t.Fatalf("%v not uploaded by %v: %v", dest, rp.name, dests)
+			}
+		}
+	}
+
+	opts.Checksums = []string{"MD5SUMS"}
+	err = opts.Validate()
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("invalid checksum file was valid: %v", err)
+	}
+}
+
+// contentProvider keeps what each artifact read as
+type contentProvider struct {
+	sync.Mutex
+	contents map[string][]byte
+}
+
+func (cp *contentProvider) Upload(id string, opts *Options,
+	in chan *artifact.Artifact, out chan *artifact.Artifact, done chan bool) {
+
+	for a := range in {
+		r, err := a.Reader()
+		if err == nil {
+			var b []byte
+			b, err = ioutil.ReadAll(r)
+			cp.Lock()
+			cp.contents[a.FullDest()] = b
+			cp.Unlock()
+		}
+
+		a.UploadResult.OK = err == nil
+		a.UploadResult.Err = err
+		out <- a
+	}
+
+	done <- true
+}
+
+func (cp *contentProvider) Name() string {
+	return "content"
+}
+
+func TestUploaderUploadSignedChecksums(t *testing.T) {
+	secret, public := testSigningKeys(t)
+
+	opts := NewOptions()
+	opts.Paths = []string{testArtifactPathDir}
+	opts.TargetPaths = []string{"artifacts/1"}
+	opts.Checksums = []string{"SHA256SUMS", "SHA512SUMS"}
+	opts.SigningKey = secret
+
+	cp := &contentProvider{contents: map[string][]byte{}}
+	u := newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{cp}
+
+	err := u.Upload()
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	verifier, err := signing.NewVerifier(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range opts.Checksums {
+		dest := "artifacts/1/" + name
+		content, ok := cp.contents[dest]
+		if !ok {
+			t.Fatalf("%v not uploaded", dest)
+		}
+
+		sig, ok := cp.contents[dest+signing.Ext]
+		if !ok {
+			t.Fatalf("%v not uploaded", dest+signing.Ext)
+		}
+
+		comment, err := verifier.Verify(bytes.NewReader(content), sig)
+		if err != nil {
+			t.Fatalf("%v signature did not verify: %v", dest, err)
+		}
+
+		if comment != "file:"+name {
+			t.Fatalf("%v != file:%v", comment, name)
+		}
+	}
+
+	if len(u.checksumDests()) != 4 {
+		t.Fatalf("checksum dests %v != 4", u.checksumDests())
+	}
+}
+
+func TestUploadOne(t *testing.T) {
+	a := artifact.New("artifacts/1", testArtifactPaths[0].Path, "SHA256SUMS", &artifact.Options{})
+	if err := uploadOne(&recordingProvider{name: "ok"}, NewOptions(), a); err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+
+	a = artifact.New("artifacts/1", testArtifactPaths[0].Path, "SHA256SUMS", &artifact.Options{})
+	if err := uploadOne(&recordingProvider{name: "failing"}, NewOptions(), a); err != errUploadFailed {
+		t.Fatalf("%v != %v", err, errUploadFailed)
+	}
+
+	a = artifact.New("artifacts/1", testArtifactPaths[0].Path, "SHA256SUMS", &artifact.Options{})
+	if err := uploadOne(&brokenProvider{}, NewOptions(), a); err != errProviderStopped {
+		t.Fatalf("%v != %v", err, errProviderStopped)
+	}
+}
@@ -0,0 +1,322 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/path"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// ConfigFileNames are the config files looked for in the working
+	// directory when none is given explicitly, in order of preference
+	ConfigFileNames = []string{
+		".artifacts.yml",
+		".artifacts.yaml",
+		".artifacts.toml",
+		".artifacts.json",
+	}
+
+	pathConfigKeys = map[string]bool{
+		"from":          true,
+		"to":            true,
+		"permissions":   true,
+		"cache-control": true,
+		"content-type":  true,
+	}
+
+	ruleConfigKeys = map[string]bool{
+		"pattern":             true,
+		"content-type":        true,
+		"content-disposition": true,
+		"cache-control":       true,
+	}
+)
+
+// FindConfigFile returns the config file to use, which is the
+// ConfigFile option if set or the first of ConfigFileNames present in
+// the working directory.  An empty string means there is none.
+func (opts *Options) FindConfigFile() string {
+	if opts.ConfigFile != "" {
+		return opts.ConfigFile
+	}
+
+	for _, name := range ConfigFileNames {
+		candidate := filepath.Join(opts.WorkingDir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// UpdateFromConfigFile loads the config file, if any, and applies each
+// setting in it that was not already set via env or CLI
+func (opts *Options) UpdateFromConfigFile() error {
+	filename := opts.FindConfigFile()
+	if filename == "" {
+		return nil
+	}
+
+	cfg, err := readConfigFile(filename)
+	if err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+
+	err = opts.updateFromConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return nil
+}
+
+func readConfigFile(filename string) (map[string]interface{}, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := map[string]interface{}{}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		err = json.Unmarshal(b, &cfg)
+	case ".toml":
+		_, err = toml.Decode(string(b), &cfg)
+	default:
+		err = yaml.Unmarshal(b, &cfg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (opts *Options) updateFromConfig(cfg map[string]interface{}) error {
+	fieldNames := map[string]string{}
+	for fieldName, key := range optsMaps["config"] {
+		if key != "" {
+			fieldNames[key] = fieldName
+		}
+	}
+
+	keys := []string{}
+	for key := range cfg {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	s := reflect.ValueOf(opts).Elem()
+
+	for _, key := range keys {
+		fieldName, ok := fieldNames[key]
+		if !ok {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+
+		if opts.explicit[fieldName] {
+			continue
+		}
+
+		value := cfg[key]
+		f := s.FieldByName(fieldName)
+
+		var err error
+		switch fieldName {
+		case "Paths":
+			err = opts.updatePathsFromConfig(value)
+		case "ContentRules":
+			err = opts.updateContentRulesFromConfig(value)
+		case "MaxSize", "PartSize":
+			var b uint64
+			b, err = parseSize(configString(value))
+			if err == nil {
+				f.SetUint(b)
+			}
+		default:
+			err = setFromConfig(f, value)
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid value for %q: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+func (opts *Options) updatePathsFromConfig(value interface{}) error {
+	entries, ok := value.([]interface{})
+	if !ok {
+		opts.Paths = splitConfigList(configString(value))
+		return nil
+	}
+
+	paths := []string{}
+	for i, entry := range entries {
+		if s, ok := entry.(string); ok {
+			paths = append(paths, os.ExpandEnv(s))
+			continue
+		}
+
+		m, err := configMap(entry)
+		if err != nil {
+			return fmt.Errorf("paths[%d]: %v", i, err)
+		}
+
+		for k := range m {
+			if !pathConfigKeys[k] {
+				return fmt.Errorf("paths[%d]: unknown key %q", i, k)
+			}
+		}
+
+		from := os.ExpandEnv(configString(m["from"]))
+		if from == "" {
+			return fmt.Errorf("paths[%d]: missing \"from\"", i)
+		}
+
+		settings := map[string]string{}
+		for _, key := range path.SettingKeys {
+			settings[key] = os.ExpandEnv(configString(m[key]))
+		}
+
+		paths = append(paths, fmt.Sprintf("%s:%s%s",
+			from, os.ExpandEnv(configString(m["to"])), path.FormatSettings(settings)))
+	}
+
+	opts.Paths = paths
+	return nil
+}
+
+func (opts *Options) updateContentRulesFromConfig(value interface{}) error {
+	entries, ok := value.([]interface{})
+	if !ok {
+		opts.ContentRules = splitConfigList(configString(value))
+		return nil
+	}
+
+	rules := []string{}
+	for i, entry := range entries {
+		if s, ok := entry.(string); ok {
+			rules = append(rules, s)
+			continue
+		}
+
+		m, err := configMap(entry)
+		if err != nil {
+			return fmt.Errorf("content-rules[%d]: %v", i, err)
+		}
+
+		for k := range m {
+			if !ruleConfigKeys[k] {
+				return fmt.Errorf("content-rules[%d]: unknown key %q", i, k)
+			}
+		}
+
+		r := &artifact.Rule{
+			Pattern:            configString(m["pattern"]),
+			ContentType:        configString(m["content-type"]),
+			ContentDisposition: configString(m["content-disposition"]),
+			CacheControl:       configString(m["cache-control"]),
+		}
+		rules = append(rules, r.String())
+	}
+
+	opts.ContentRules = rules
+	return nil
+}
+
+func setFromConfig(f reflect.Value, value interface{}) error {
+	switch f.Kind() {
+	case reflect.String:
+		if entries, ok := value.([]interface{}); ok {
+			list := []string{}
+			for _, entry := range entries {
+				list = append(list, configString(entry))
+			}
+			value = strings.Join(list, ",")
+		}
+		f.SetString(os.ExpandEnv(configString(value)))
+	case reflect.Uint64:
+		uintVal, err := strconv.ParseUint(configString(value), 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetUint(uintVal)
+	case reflect.Bool:
+		boolVal, err := strconv.ParseBool(configString(value))
+		if err != nil {
+			return err
+		}
+		f.SetBool(boolVal)
+	case reflect.Slice:
+		var list []string
+		if entries, ok := value.([]interface{}); ok {
+			list = []string{}
+			for _, entry := range entries {
+				list = append(list, os.ExpandEnv(configString(entry)))
+			}
+		} else {
+			list = splitConfigList(configString(value))
+		}
+		f.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported kind %v", f.Kind())
+	}
+
+	return nil
+}
+
+// configMap normalizes the map types produced by the various decoders
+func configMap(value interface{}) (map[string]interface{}, error) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, nil
+	case map[interface{}]interface{}:
+		ret := map[string]interface{}{}
+		for k, v := range m {
+			ret[fmt.Sprintf("%v", k)] = v
+		}
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("expected a string or mapping, got %v", value)
+	}
+}
+
+func configString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func splitConfigList(value string) []string {
+	list := []string{}
+	for _, part := range strings.Split(value, ":") {
+		trimmed := strings.TrimSpace(part)
+		if trimmed != "" {
+			list = append(list, os.ExpandEnv(trimmed))
+		}
+	}
+	return list
+}
@@ -0,0 +1,173 @@
+package upload
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestConfig(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir(testTmp, "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestOptionsUpdateFromConfigFileYAML(t *testing.T) {
+	os.Clearenv()
+	dir := writeTestConfig(t, ".artifacts.yml", `
+bucket: my-bucket
+max-size: 2MB
+retries: 4
+target-paths:
+- artifacts/1
+- artifacts/latest
+paths:
+- log/
+- from: coverage/
+  to: cov
+- from: site/
+  permissions: public-read
+  cache-control: max-age=60, public
+`)
+
+	opts := NewOptions()
+	opts.WorkingDir = dir
+
+	err := opts.UpdateFromConfigFile()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if opts.BucketName != "my-bucket" {
+		t.Fatalf("bucket %v != my-bucket", opts.BucketName)
+	}
+
+	if opts.MaxSize != uint64(2000000) {
+		t.Fatalf("max size %v != 2000000", opts.MaxSize)
+	}
+
+	if opts.Retries != uint64(4) {
+		t.Fatalf("retries %v != 4", opts.Retries)
+	}
+
+	if !reflect.DeepEqual(opts.TargetPaths, []string{"artifacts/1", "artifacts/latest"}) {
+		t.Fatalf("unexpected target paths %v", opts.TargetPaths)
+	}
+
+	if !reflect.DeepEqual(opts.Paths, []string{
+		"log/",
+		"coverage/:cov",
+		"site/:?permissions=public-read&cache-control=max-age=60, public",
+	}) {
+		t.Fatalf("unexpected paths %v", opts.Paths)
+	}
+}
+
+func TestOptionsUpdateFromConfigFileJSON(t *testing.T) {
+	os.Clearenv()
+	dir := writeTestConfig(t, "custom.json", `{"concurrency": 9, "upload-provider": "null"}`)
+
+	opts := NewOptions()
+	opts.ConfigFile = filepath.Join(dir, "custom.json")
+
+	err := opts.UpdateFromConfigFile()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if opts.Concurrency != uint64(9) {
+		t.Fatalf("concurrency %v != 9", opts.Concurrency)
+	}
+
+	if opts.Provider != "null" {
+		t.Fatalf("provider %v != null", opts.Provider)
+	}
+}
+
+func TestOptionsUpdateFromConfigFileTOML(t *testing.T) {
+	os.Clearenv()
+	dir := writeTestConfig(t, ".artifacts.toml", "bucket = \"toml-bucket\"\nretries = 1\n")
+
+	opts := NewOptions()
+	opts.WorkingDir = dir
+
+	err := opts.UpdateFromConfigFile()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if opts.BucketName != "toml-bucket" {
+		t.Fatalf("bucket %v != toml-bucket", opts.BucketName)
+	}
+
+	if opts.Retries != uint64(1) {
+		t.Fatalf("retries %v != 1", opts.Retries)
+	}
+}
+
+func TestOptionsUpdateFromConfigFileUnknownKey(t *testing.T) {
+	os.Clearenv()
+
+	for _, content := range []string{
+		"buckit: nope\n",
+		"paths:\n- from: foo\n  too: bar\n",
+	} {
+		dir := writeTestConfig(t, ".artifacts.yml", content)
+
+		opts := NewOptions()
+		opts.WorkingDir = dir
+
+		if opts.UpdateFromConfigFile() == nil {
+			t.Fatalf("config with unknown key was accepted: %q", content)
+		}
+	}
+}
+
+func TestOptionsUpdateFromConfigFilePrecedence(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("ARTIFACTS_S3_BUCKET", "env-bucket")
+	defer os.Clearenv()
+
+	dir := writeTestConfig(t, ".artifacts.yml", "bucket: config-bucket\npermissions: public-read\n")
+
+	opts := NewOptions()
+	opts.WorkingDir = dir
+
+	err := opts.UpdateFromConfigFile()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if opts.BucketName != "env-bucket" {
+		t.Fatalf("bucket %v != env-bucket", opts.BucketName)
+	}
+
+	if opts.Perm != "public-read" {
+		t.Fatalf("permissions %v != public-read", opts.Perm)
+	}
+}
+
+func TestOptionsUpdateFromConfigFileMissing(t *testing.T) {
+	os.Clearenv()
+	opts := NewOptions()
+	opts.WorkingDir = testTmp
+
+	if opts.UpdateFromConfigFile() != nil {
+		t.Fatalf("missing optional config file was an error")
+	}
+
+	opts.ConfigFile = filepath.Join(testTmp, "does-not-exist.yml")
+	if opts.UpdateFromConfigFile() == nil {
+		t.Fatalf("missing explicit config file was not an error")
+	}
+}
@@ -0,0 +1,30 @@
+package upload
+
+import (
+	"github.com/travis-ci/artifacts/artifact"
+)
+
+// LoadContentRules parses the ContentRules option and loads the
+// MimeTypesFile option, if any.  The parsed rules come first so that
+// they take precedence over the mime types file.
+func (opts *Options) LoadContentRules() (artifact.Rules, error) {
+	rules := artifact.Rules{}
+
+	for _, spec := range opts.ContentRules {
+		r, err := artifact.ParseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	if opts.MimeTypesFile != "" {
+		mimeRules, err := artifact.LoadMimeTypes(opts.MimeTypesFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, mimeRules...)
+	}
+
+	return rules, nil
+}
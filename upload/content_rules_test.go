@@ -0,0 +1,71 @@
+package upload
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOptionsLoadContentRules(t *testing.T) {
+	os.Clearenv()
+	filename := filepath.Join(testTmp, "content-rules.types")
+	err := ioutil.WriteFile(filename, []byte("text/plain log\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := NewOptions()
+	opts.ContentRules = []string{"*.junit?content-type=application/xml"}
+	opts.MimeTypesFile = filename
+
+	rules, err := opts.LoadContentRules()
+	if err != nil {
+		t.Fatalf("failed to load rules: %v", err)
+	}
+
+	if len(rules) != 2 {
+		t.Fatalf("rules length %v != 2", len(rules))
+	}
+
+	if rules[0].Pattern != "*.junit" || rules[1].Pattern != ".log" {
+		t.Fatalf("unexpected rule order %v, %v", rules[0], rules[1])
+	}
+
+	opts.MimeTypesFile = filepath.Join(testTmp, "does-not-exist.types")
+	if _, err := opts.LoadContentRules(); err == nil {
+		t.Fatalf("missing mime types file was not an error")
+	}
+}
+
+func TestOptionsUpdateFromConfigFileContentRules(t *testing.T) {
+	os.Clearenv()
+	dir := writeTestConfig(t, ".artifacts.yml", `
+content-rules:
+- "*.txt?content-type=text/plain"
+- pattern: .log
+  content-type: text/plain; charset=utf-8
+  content-disposition: inline
+`)
+
+	opts := NewOptions()
+	opts.WorkingDir = dir
+
+	err := opts.UpdateFromConfigFile()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	rules, err := opts.LoadContentRules()
+	if err != nil {
+		t.Fatalf("failed to load rules: %v", err)
+	}
+
+	if len(rules) != 2 {
+		t.Fatalf("rules length %v != 2", len(rules))
+	}
+
+	if rules[1].ContentType != "text/plain; charset=utf-8" || rules[1].ContentDisposition != "inline" {
+		t.Fatalf("unexpected rule %v", rules[1])
+	}
+}
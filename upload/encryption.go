@@ -0,0 +1,57 @@
+package upload
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/encryption"
+)
+
+const (
+	encryptedContentType = "application/octet-stream"
+)
+
+func (opts *Options) validateEncryption() error {
+	if opts.EncryptionPassphrase == "" {
+		return nil
+	}
+
+	for _, name := range opts.ProviderNames() {
+		if name != "s3" && name != "null" {
+			return fmt.Errorf("encryption is only supported by the s3 provider")
+		}
+	}
+
+	if opts.CAS {
+		return fmt.Errorf("encryption can't be combined with content-addressed storage")
+	}
+
+	return nil
+}
+
+// encryptedUpload encrypts the artifact as it is uploaded, storing what
+// is needed to decrypt it in the object's metadata.  Big ones go up in
+// parts, but are never resumed, as each attempt has a new data key and
+// the one a multipart upload was started with is only in its metadata.
+func (s3p *s3Provider) encryptedUpload(opts *Options, b *s3.Bucket, key string, a *artifact.Artifact,
+	reader io.Reader, size uint64, headers map[string][]string) error {
+
+	encrypted, encryptionHeaders, err := s3p.encryption.Encrypt(reader)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range encryptionHeaders {
+		headers[k] = v
+	}
+	headers["Content-Type"] = []string{encryptedContentType}
+
+	encryptedSize := encryption.EncryptedSize(size)
+	if encryptedSize > opts.PartSize {
+		return s3p.multipartUpload(b, key, headers, encrypted, a, opts.PartSize, false)
+	}
+
+	return b.PutReaderHeader(key, encrypted, int64(encryptedSize), headers, a.Perm)
+}
@@ -0,0 +1,92 @@
+package upload
+
+import (
+	"io"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/journal"
+)
+
+// openJournal opens the journal file if one was given.  A journal that
+// can't be read is ignored rather than failing the run, as it only
+// saves work.
+func openJournal(opts *Options, log *logrus.Logger) *journal.Journal {
+	if opts.JournalFile == "" {
+		return nil
+	}
+
+	j, err := journal.Open(opts.JournalFile)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"journal": opts.JournalFile,
+			"err":     err,
+		}).Warn("ignoring unreadable journal")
+		return nil
+	}
+
+	log.WithFields(logrus.Fields{
+		"journal":   opts.JournalFile,
+		"completed": len(j.Completed),
+		"multipart": len(j.Multiparts),
+	}).Debug("opened journal")
+
+	return j
+}
+
+// journalChecksum hashes the artifact as it is uploaded, so that
+// recording it in the journal needn't read it all over again
+func (u *uploader) journalChecksum(a *artifact.Artifact, size uint64) {
+	if u.journal == nil {
+		return
+	}
+
+	a.AddReaderWrapper(func(r io.Reader) io.Reader {
+		return newChecksumReader(r, []string{"SHA256SUMS"}, func(sums map[string]string, n uint64) {
+			if n == size {
+				a.UploadResult.Checksum = sums["SHA256SUMS"]
+			}
+		})
+	})
+}
+
+func (u *uploader) journalResult(a *artifact.Artifact) {
+	if !a.UploadResult.OK {
+		return
+	}
+
+	err := u.journal.Complete(a.UploadResult.Provider, a.FullDest(), a.Source, a.UploadResult.Checksum)
+	if err != nil {
+		u.log.WithFields(logrus.Fields{
+			"dest": a.FullDest(),
+			"err":  err,
+		}).Warn("failed to record artifact in journal")
+	}
+}
+
+// saveJournal writes the completions not yet written, so that a failed
+// or interrupted run can pick up where it left off
+func (u *uploader) saveJournal() {
+	err := u.journal.Save()
+	if err != nil {
+		u.log.WithFields(logrus.Fields{
+			"journal": u.Opts.JournalFile,
+			"err":     err,
+		}).Warn("failed to save journal")
+	}
+}
+
+func (u *uploader) removeJournal() {
+	err := u.journal.Remove()
+	if err != nil {
+		u.log.WithFields(logrus.Fields{
+			"journal": u.Opts.JournalFile,
+			"err":     err,
+		}).Warn("failed to remove journal")
+		return
+	}
+
+	if u.journal != nil {
+		u.log.WithField("journal", u.Opts.JournalFile).Debug("removed journal")
+	}
+}
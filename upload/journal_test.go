@@ -0,0 +1,50 @@
+package upload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUploaderUploadJournal(t *testing.T) {
+	journalFile := filepath.Join(testTmp, "journal.json")
+	os.Remove(journalFile)
+
+	opts := NewOptions()
+	opts.Paths = []string{testArtifactPathDir}
+	opts.TargetPaths = []string{"artifacts/1"}
+	opts.JournalFile = journalFile
+
+	ok := &recordingProvider{name: "ok"}
+	failing := &recordingProvider{name: "failing"}
+
+	u := newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{ok, failing}
+	u.Upload()
+
+	if len(ok.uploaded) == 0 {
+		t.Fatalf("nothing was uploaded")
+	}
+
+	if _, err := os.Stat(journalFile); err != nil {
+		t.Fatalf("journal not kept after failures: %v", err)
+	}
+
+	rerun := &recordingProvider{name: "ok"}
+
+	u = newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{rerun}
+
+	err := u.Upload()
+	if err != nil {
+		t.Fatalf("rerun failed: %v", err)
+	}
+
+	if len(rerun.uploaded) != 0 {
+		t.Fatalf("rerun uploaded %v completed artifacts", len(rerun.uploaded))
+	}
+
+	if _, err := os.Stat(journalFile); !os.IsNotExist(err) {
+		t.Fatalf("journal not removed after success: %v", err)
+	}
+}
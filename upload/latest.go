@@ -0,0 +1,266 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/encryption"
+	"github.com/travis-ci/artifacts/remote"
+)
+
+const (
+	// LatestModeCopy publishes latest aliases as server-side copies
+	LatestModeCopy = "copy"
+	// LatestModeRedirect publishes latest aliases as empty objects
+	// redirecting to the artifacts, for buckets served as websites
+	LatestModeRedirect = "redirect"
+
+	latestCopyPartSize = int64(1024 * 1024 * 1024)
+)
+
+var (
+	latestModes = map[string]bool{
+		LatestModeCopy:     true,
+		LatestModeRedirect: true,
+	}
+)
+
+// latestSet collects the artifacts going to s3, including those skipped
+// as already uploaded, so that aliases cover everything.  A nil
+// *latestSet is valid and collects nothing.
+type latestSet struct {
+	sync.Mutex
+
+	artifacts []*artifact.Artifact
+}
+
+func (ls *latestSet) Add(a *artifact.Artifact) {
+	if ls == nil {
+		return
+	}
+
+	ls.Lock()
+	defer ls.Unlock()
+	ls.artifacts = append(ls.artifacts, a)
+}
+
+// aliases maps each alias key under the prefix to the artifact it is
+// for, preferring the first target path when there are several
+func (ls *latestSet) aliases(prefix string, targetPaths []string) map[string]*artifact.Artifact {
+	ls.Lock()
+	defer ls.Unlock()
+
+	rank := map[string]int{}
+	for i := len(targetPaths) - 1; i >= 0; i-- {
+		rank[targetPaths[i]] = i
+	}
+
+	aliases := map[string]*artifact.Artifact{}
+	for _, a := range ls.artifacts {
+		key := strings.TrimLeft(filepath.ToSlash(filepath.Join(prefix, a.Dest)), "/")
+		if other, ok := aliases[key]; ok && rank[other.Prefix] <= rank[a.Prefix] {
+			continue
+		}
+		aliases[key] = a
+	}
+
+	return aliases
+}
+
+func (opts *Options) validateLatest() error {
+	if len(opts.LatestBranches) == 0 && len(opts.LatestTags) == 0 {
+		return nil
+	}
+
+	if !latestModes[opts.LatestMode] {
+		return fmt.Errorf("invalid latest mode %q", opts.LatestMode)
+	}
+
+	if strings.Trim(opts.LatestPrefix, "/") == "" {
+		return fmt.Errorf("latest prefix may not be empty")
+	}
+
+	if !opts.hasProvider("s3") {
+		return fmt.Errorf("latest aliases are only supported by the s3 provider")
+	}
+
+	for _, pattern := range append(opts.LatestBranches, opts.LatestTags...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid latest pattern %q: %v", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// latestAlias returns the tag or branch being built if latest aliases
+// are to be published for it, and never for pull requests
+func (opts *Options) latestAlias() string {
+	if opts.PullRequest != "" && opts.PullRequest != "false" {
+		return ""
+	}
+
+	if opts.Tag != "" {
+		if matchesAny(opts.LatestTags, opts.Tag) {
+			return opts.Tag
+		}
+		return ""
+	}
+
+	if opts.Branch != "" && matchesAny(opts.LatestBranches, opts.Branch) {
+		return opts.Branch
+	}
+
+	return ""
+}
+
+// latestPrefix is where the aliases for this build go
+func (opts *Options) latestPrefix() string {
+	return strings.Trim(opts.LatestPrefix, "/") + "/" + opts.latestAlias() + "/"
+}
+
+// staleAliases picks the keys under this build's prefix that are not
+// among its aliases, leaving those of deeper aliases alone, e.g. those
+// of branch "release/1.0" under the prefix of branch "release"
+func (opts *Options) staleAliases(existing []s3.Key, aliases map[string]*artifact.Artifact) []string {
+	prefix := opts.latestPrefix()
+	stale := []string{}
+
+	for _, k := range existing {
+		if _, ok := aliases[k.Key]; ok {
+			continue
+		}
+
+		if opts.isDeeperAlias(strings.TrimPrefix(k.Key, prefix)) {
+			continue
+		}
+
+		stale = append(stale, k.Key)
+	}
+
+	return stale
+}
+
+// isDeeperAlias tells whether a key relative to this build's prefix is
+// under the prefix of another branch or tag that aliases are published
+// for, as its directories joined to this alias make a matching name
+func (opts *Options) isDeeperAlias(rel string) bool {
+	parts := strings.Split(rel, "/")
+	name := opts.latestAlias()
+
+	for _, part := range parts[:len(parts)-1] {
+		name += "/" + part
+		if matchesAny(opts.LatestBranches, name) || matchesAny(opts.LatestTags, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// publishLatest points the aliases for the branch or tag at this
+// build's artifacts, and deletes aliases left from earlier builds.  It
+// is only called once every artifact succeeded, and does nothing if
+// the walk was incomplete.
+func (u *uploader) publishLatest() error {
+	if u.latest == nil {
+		return nil
+	}
+
+	prefix := u.Opts.latestPrefix()
+	logFields := logrus.Fields{
+		"prefix": prefix,
+		"mode":   u.Opts.LatestMode,
+	}
+
+	if u.incomplete {
+		u.log.WithFields(logFields).Warn("not publishing latest aliases after an incomplete walk")
+		return nil
+	}
+
+	bucket, err := u.Opts.bucket()
+	if err != nil {
+		return err
+	}
+
+	aliases := u.latest.aliases(prefix, u.Opts.TargetPaths)
+	keys := []string{}
+	for key := range aliases {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		a := aliases[key]
+		err := u.publishAlias(bucket, key, a)
+		if err != nil {
+			return fmt.Errorf("failed to publish latest alias %s: %v", key, err)
+		}
+
+		u.log.WithFields(logrus.Fields{
+			"dest": a.FullDest(),
+		}).Debug(fmt.Sprintf("published latest alias: %s", key))
+	}
+
+	existing, err := remote.Keys(bucket, prefix)
+	if err != nil {
+		return err
+	}
+
+	stale := u.Opts.staleAliases(existing, aliases)
+	err = remote.Delete(bucket, stale)
+	if err != nil {
+		return err
+	}
+
+	logFields["count"] = len(keys)
+	logFields["stale"] = len(stale)
+	u.log.WithFields(logFields).Info("published latest aliases")
+	return nil
+}
+
+func (u *uploader) publishAlias(bucket *s3.Bucket, key string, a *artifact.Artifact) error {
+	if u.Opts.LatestMode == LatestModeRedirect {
+		headers := map[string][]string{
+			"Content-Type":                    []string{a.ContentType()},
+			"x-amz-website-redirect-location": []string{"/" + a.FullDest()},
+		}
+		return bucket.PutReaderHeader(key, bytes.NewReader([]byte{}), 0, headers, a.Perm)
+	}
+
+	headers, err := remote.Head(bucket, a.FullDest())
+	if err != nil {
+		return err
+	}
+
+	size, err := a.Size()
+	if err != nil {
+		return err
+	}
+
+	if u.Opts.CAS {
+		// the artifact is a pointer to its blob
+		size = 0
+	} else if u.Opts.EncryptionPassphrase != "" && a.SymlinkTarget == "" {
+		size = encryption.EncryptedSize(size)
+	}
+
+	return remote.Copy(bucket, a.FullDest(), key, int64(size), latestCopyPartSize, headers, a.Perm)
+}
@@ -0,0 +1,98 @@
+package upload
+
+import (
+	"testing"
+
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/artifact"
+)
+
+func TestOptionsLatestAlias(t *testing.T) {
+	for _, tc := range []struct {
+		branch, tag, pr string
+		expected        string
+	}{
+		{branch: "master", pr: "false", expected: "master"},
+		{branch: "release/1.x", pr: "false", expected: ""},
+		{branch: "release-1.x", pr: "false", expected: "release-1.x"},
+		{branch: "master", pr: "42", expected: ""},
+		{branch: "v1.2.3", tag: "v1.2.3", pr: "false", expected: "v1.2.3"},
+		{branch: "nightly", tag: "nightly", pr: "false", expected: ""},
+		{branch: "feature", pr: "false", expected: ""},
+	} {
+		opts := NewOptions()
+		opts.LatestBranches = []string{"master", "release-*"}
+		opts.LatestTags = []string{"v*"}
+		opts.Branch, opts.Tag, opts.PullRequest = tc.branch, tc.tag, tc.pr
+
+		if opts.latestAlias() != tc.expected {
+			t.Fatalf("%s/%s/%s: %q != %q", tc.branch, tc.tag, tc.pr, opts.latestAlias(), tc.expected)
+		}
+	}
+}
+
+func TestOptionsValidateLatest(t *testing.T) {
+	opts := NewOptions()
+	opts.DryRun = true
+	opts.LatestBranches = []string{"master"}
+	opts.LatestMode = "symlink"
+
+	if opts.Validate() == nil {
+		t.Fatalf("invalid latest mode was valid")
+	}
+
+	opts.LatestMode = LatestModeRedirect
+	opts.LatestBranches = []string{"[master"}
+	if opts.Validate() == nil {
+		t.Fatalf("invalid latest pattern was valid")
+	}
+}
+
+func TestLatestSetAliases(t *testing.T) {
+	ls := &latestSet{}
+	ls.Add(&artifact.Artifact{Prefix: "artifacts/latest", Dest: "a.txt"})
+	ls.Add(&artifact.Artifact{Prefix: "artifacts/1/1.1", Dest: "a.txt"})
+	ls.Add(&artifact.Artifact{Prefix: "artifacts/1/1.1", Dest: "logs/b.txt"})
+
+	aliases := ls.aliases("latest/master/", []string{"artifacts/1/1.1", "artifacts/latest"})
+	if len(aliases) != 2 {
+		t.Fatalf("%v != 2", len(aliases))
+	}
+
+	if a := aliases["latest/master/a.txt"]; a == nil || a.Prefix != "artifacts/1/1.1" {
+		t.Fatalf("unexpected alias for a.txt: %v", a)
+	}
+
+	if aliases["latest/master/logs/b.txt"] == nil {
+		t.Fatalf("missing alias for logs/b.txt")
+	}
+}
+
+func TestOptionsStaleAliasesNestedBranches(t *testing.T) {
+	opts := NewOptions()
+	opts.LatestBranches = []string{"release", "release/1.*"}
+	opts.Branch, opts.PullRequest = "release", "false"
+
+	existing := []s3.Key{
+		{Key: "latest/release/a.txt"},
+		{Key: "latest/release/old.txt"},
+		{Key: "latest/release/docs/old.txt"},
+		{Key: "latest/release/1.0/a.txt"},
+		{Key: "latest/release/1.0/logs/b.txt"},
+	}
+	aliases := map[string]*artifact.Artifact{
+		"latest/release/a.txt": &artifact.Artifact{},
+	}
+
+	stale := opts.staleAliases(existing, aliases)
+	expected := []string{"latest/release/old.txt", "latest/release/docs/old.txt"}
+	if len(stale) != len(expected) {
+		t.Fatalf("stale %v != %v", stale, expected)
+	}
+
+	for i, key := range expected {
+		if stale[i] != key {
+			t.Fatalf("%v != %v", stale[i], key)
+		}
+	}
+}
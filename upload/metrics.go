@@ -0,0 +1,122 @@
+package upload
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/metrics"
+)
+
+const (
+	metricsJobName = "artifacts"
+)
+
+type uploadMetrics struct {
+	Registry *metrics.Registry
+
+	uploaded *metrics.Counter
+	failed   *metrics.Counter
+	skipped  *metrics.Counter
+	bytes    *metrics.Counter
+	retries  *metrics.Counter
+	latency  *metrics.Histogram
+
+	maxSizeUtilization *metrics.Gauge
+	runDuration        *metrics.Gauge
+	lastRun            *metrics.Gauge
+}
+
+func newUploadMetrics() *uploadMetrics {
+	r := metrics.NewRegistry()
+
+	return &uploadMetrics{
+		Registry: r,
+
+		uploaded: r.Counter("artifacts_uploaded_total",
+			"Number of artifacts uploaded successfully."),
+		failed: r.Counter("artifacts_failed_total",
+			"Number of artifacts that failed to upload."),
+		skipped: r.Counter("artifacts_skipped_total",
+			"Number of artifacts that were not uploaded."),
+		bytes: r.Counter("artifacts_uploaded_bytes_total",
+			"Number of bytes uploaded successfully."),
+		retries: r.Counter("artifacts_upload_retries_total",
+			"Number of artifact upload retries."),
+		latency: r.Histogram("artifacts_upload_duration_seconds",
+			"Time taken to upload each artifact, including retries.", nil),
+
+		maxSizeUtilization: r.Gauge("artifacts_max_size_utilization_percent",
+			"Combined artifact size as a percentage of the max size."),
+		runDuration: r.Gauge("artifacts_run_duration_seconds",
+			"Time taken by the whole upload run."),
+		lastRun: r.Gauge("artifacts_last_run_timestamp_seconds",
+			"Unix time at which the last upload run finished."),
+	}
+}
+
+// Record counts the result of a single artifact upload
+func (um *uploadMetrics) Record(provider string, a *artifact.Artifact) {
+	labels := metrics.Labels{"provider": provider}
+
+	if a.UploadResult.OK {
+		um.uploaded.Inc(labels)
+		size, err := a.Size()
+		if err == nil {
+			um.bytes.Add(labels, float64(size))
+		}
+	} else {
+		um.failed.Inc(labels)
+	}
+
+	um.retries.Add(labels, float64(a.UploadResult.Retries))
+	um.latency.Observe(labels, a.UploadResult.Duration.Seconds())
+}
+
+// Skip counts an artifact that was never handed to the provider
+func (um *uploadMetrics) Skip(provider string) {
+	um.skipped.Inc(metrics.Labels{"provider": provider})
+}
+
+// Finish records run-wide values
+func (um *uploadMetrics) Finish(currentSize, maxSize uint64, startTime time.Time) {
+	um.maxSizeUtilization.Set(nil, pctMax(currentSize, maxSize))
+	um.runDuration.Set(nil, time.Since(startTime).Seconds())
+	um.lastRun.Set(nil, float64(time.Now().Unix()))
+}
+
+func (u *uploader) writeMetrics() {
+	if u.Opts.MetricsFile == "" && u.Opts.MetricsPushURL == "" {
+		return
+	}
+
+	currentSize := uint64(0)
+	if u.curSize != nil {
+		currentSize = u.curSize.Current
+	}
+	u.metrics.Finish(currentSize, u.Opts.MaxSize, u.startTime)
+
+	if u.Opts.MetricsFile != "" {
+		err := u.metrics.Registry.WriteFile(u.Opts.MetricsFile)
+		if err != nil {
+			u.log.WithFields(logrus.Fields{
+				"file": u.Opts.MetricsFile,
+				"err":  err,
+			}).Error("failed to write metrics file")
+		} else {
+			u.log.WithField("file", u.Opts.MetricsFile).Debug("wrote metrics file")
+		}
+	}
+
+	if u.Opts.MetricsPushURL != "" {
+		err := u.metrics.Registry.Push(u.Opts.MetricsPushURL, metricsJobName)
+		if err != nil {
+			u.log.WithFields(logrus.Fields{
+				"url": u.Opts.MetricsPushURL,
+				"err": err,
+			}).Error("failed to push metrics")
+		} else {
+			u.log.WithField("url", u.Opts.MetricsPushURL).Debug("pushed metrics")
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package upload
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/remote"
+)
+
+// mirror tracks what is already under the target paths and what the
+// walk found locally, so that unchanged artifacts can be skipped and
+// stale ones deleted.  A nil *mirror is valid and does nothing.
+type mirror struct {
+	sync.Mutex
+
+	bucket     *s3.Bucket
+	remote     map[string]s3.Key
+	local      map[string]bool
+	incomplete bool
+}
+
+func newMirror(opts *Options, log *logrus.Logger) (*mirror, error) {
+	bucket, err := opts.bucket()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mirror{
+		bucket: bucket,
+		remote: map[string]s3.Key{},
+		local:  map[string]bool{},
+	}
+
+	casPrefix := strings.Trim(opts.CASPrefix, "/") + "/"
+
+	for _, targetPath := range opts.TargetPaths {
+		prefix := strings.Trim(targetPath, "/") + "/"
+		keys, err := remote.Keys(bucket, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, k := range keys {
+			// blobs are shared by every target path
+			if opts.CAS && strings.HasPrefix(k.Key, casPrefix) {
+				continue
+			}
+			m.remote[k.Key] = k
+		}
+
+		log.WithFields(logrus.Fields{
+			"prefix": prefix,
+			"keys":   len(keys),
+		}).Debug("listed remote artifacts")
+	}
+
+	return m, nil
+}
+
+// bucket connects to the s3 bucket, for the listings, copies and
+// deletions that the s3 provider does not do itself
+func (opts *Options) bucket() (*s3.Bucket, error) {
+	ro := &remote.Options{
+		AccessKey:  opts.AccessKey,
+		SecretKey:  opts.SecretKey,
+		BucketName: opts.BucketName,
+		S3Region:   opts.S3Region,
+	}
+	return ro.Bucket()
+}
+
+// Seen records that the walk found dest locally
+func (m *mirror) Seen(dest string) {
+	if m == nil {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	m.local[dest] = true
+}
+
+// Incomplete records that the walk did not finish, so that artifacts
+// it never got to are not taken for stale ones
+func (m *mirror) Incomplete() {
+	if m == nil {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	m.incomplete = true
+}
+
+// Unchanged tells if dest is already uploaded with the same size and
+// MD5 as source; multipart uploads never match, as their ETag is not
+// the MD5 of the whole
+func (m *mirror) Unchanged(dest, source string, size uint64) bool {
+	if m == nil {
+		return false
+	}
+
+	m.Lock()
+	k, ok := m.remote[dest]
+	m.Unlock()
+
+	if !ok || uint64(k.Size) != size {
+		return false
+	}
+
+	sum, err := fileMD5(source)
+	return err == nil && strings.Trim(k.ETag, `"`) == sum
+}
+
+// Stale returns the remote keys the walk did not find locally
+func (m *mirror) Stale() ([]string, error) {
+	if m == nil {
+		return []string{}, nil
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if m.incomplete {
+		return nil, fmt.Errorf("not deleting stale artifacts after an incomplete walk")
+	}
+
+	stale := []string{}
+	for key := range m.remote {
+		if !m.local[key] {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(stale)
+
+	return stale, nil
+}
+
+// deleteStale deletes the remote keys that no longer exist locally, as
+// long as there are no more than the max deletions
+func (u *uploader) deleteStale() error {
+	stale, err := u.staleKeys()
+	if err != nil || len(stale) == 0 {
+		return err
+	}
+
+	err = remote.Delete(u.mirror.bucket, stale)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range stale {
+		u.log.Info(fmt.Sprintf("deleted stale: %s", key))
+	}
+
+	return nil
+}
+
+func (u *uploader) staleKeys() ([]string, error) {
+	stale, err := u.mirror.Stale()
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(len(stale)) > u.Opts.MaxDeletions {
+		return stale, fmt.Errorf("refusing to delete %d stale artifacts, more than max deletions %d",
+			len(stale), u.Opts.MaxDeletions)
+	}
+
+	return stale, nil
+}
+
+func fileMD5(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,75 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mitchellh/goamz/s3"
+)
+
+func TestUploaderDryRunMirror(t *testing.T) {
+	dir, err := ioutil.TempDir(testTmp, "mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range map[string]string{"a.txt": "hello", "b.txt": "world"} {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := NewOptions()
+	opts.DryRun = true
+	opts.DryRunFormat = PlanFormatJSON
+	opts.Paths = []string{dir}
+	opts.TargetPaths = []string{"artifacts/1"}
+
+	out := &bytes.Buffer{}
+
+	u := newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{&recordingProvider{name: "s3"}}
+	u.planOut = out
+	u.mirror = &mirror{
+		remote: map[string]s3.Key{
+			// md5 of "hello"
+			"artifacts/1/a.txt":   {Key: "artifacts/1/a.txt", Size: 5, ETag: `"5d41402abc4b2a76b9719d911017c592"`},
+			"artifacts/1/b.txt":   {Key: "artifacts/1/b.txt", Size: 5, ETag: `"5d41402abc4b2a76b9719d911017c592"`},
+			"artifacts/1/old.txt": {Key: "artifacts/1/old.txt", Size: 3},
+		},
+		local: map[string]bool{},
+	}
+
+	err = u.Upload()
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+
+	plan := &uploadPlan{}
+	err = json.Unmarshal(out.Bytes(), plan)
+	if err != nil {
+		t.Fatalf("plan is not JSON: %v", err)
+	}
+
+	if len(plan.Artifacts) != 1 || plan.Artifacts[0].Dest != "artifacts/1/b.txt" {
+		t.Fatalf("unexpected artifacts %v", plan.Artifacts)
+	}
+
+	if len(plan.Deletions) != 1 || plan.Deletions[0] != "artifacts/1/old.txt" {
+		t.Fatalf("unexpected deletions %v", plan.Deletions)
+	}
+
+	opts.MaxDeletions = 0
+	if _, err := u.staleKeys(); err == nil {
+		t.Fatalf("max deletions was not enforced")
+	}
+
+	u.mirror.Incomplete()
+	if stale, err := u.mirror.Stale(); err == nil || stale != nil {
+		t.Fatalf("stale keys after incomplete walk: %v", stale)
+	}
+}
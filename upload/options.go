@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
 
 	"github.com/codegangsta/cli"
 	"github.com/dustin/go-humanize"
+	"github.com/travis-ci/artifacts/ci"
 	"github.com/travis-ci/artifacts/env"
 )
 
@@ -23,8 +25,9 @@ provided as either positional command-line arguments or as the $ARTIFACTS_PATHS
 environment variable, which should be :-delimited.
 
 Paths may be either files or directories.  Any path provided will be walked for
-all child entries.  Each entry will have its mime type detected based first on
-the file extension, then by sniffing up to the first 512 bytes via the net/http
+all child entries.  Each entry will have its mime type taken from the first
+matching content rule, if any, or else detected based first on the file
+extension, then by sniffing up to the first 512 bytes via the net/http
 function "DetectContentType".
 `
 )
@@ -46,17 +49,65 @@ var (
 			"BuildID":     "build-id",
 			"JobNumber":   "job-number",
 			"JobID":       "job-id",
+			"Branch":      "branch",
+			"Tag":         "tag",
+			"PullRequest": "pull-request",
+			"Commit":      "commit",
+			"OS":          "os",
 
-			"Concurrency": "concurrency",
-			"MaxSize":     "max-size",
-			"Paths":       "",
-			"Provider":    "upload-provider, p",
-			"Retries":     "retries",
-			"TargetPaths": "target-paths, t",
-			"WorkingDir":  "working-dir",
+			"Concurrency":   "concurrency",
+			"MaxSize":       "max-size",
+			"Paths":         "",
+			"Provider":      "upload-provider, p",
+			"FailurePolicy": "failure-policy",
+			"Retries":       "retries",
+			"TargetPaths":   "target-paths, t",
+			"WorkingDir":    "working-dir",
+
+			"ContentRules":  "content-rules",
+			"MimeTypesFile": "mime-types-file",
+
+			"Symlinks":    "symlinks",
+			"HiddenFiles": "hidden-files",
+
+			"DryRun":       "dry-run",
+			"DryRunFormat": "dry-run-format",
+
+			"JournalFile": "journal",
+			"PartSize":    "part-size",
+
+			"CAS":       "cas",
+			"CASPrefix": "cas-prefix",
+
+			"Delete":       "delete",
+			"MaxDeletions": "max-deletions",
+
+			"LatestBranches": "latest-branches",
+			"LatestTags":     "latest-tags",
+			"LatestPrefix":   "latest-prefix",
+			"LatestMode":     "latest-mode",
+
+			"SummaryMarkdown":   "summary-markdown",
+			"SigningKey":        "signing-key",
+			"Checksums":         "checksums",
+			"Provenance":        "provenance",
+			"ProvenanceBuilder": "provenance-builder",
+
+			"EncryptionPassphrase": "encryption-passphrase",
+
+			"ShutdownTimeout":  "shutdown-timeout",
+			"ProgressInterval": "progress-interval",
+
+			"MetricsFile":    "metrics-file",
+			"MetricsPushURL": "metrics-push-url",
+
+			"TracingEndpoint": "tracing-endpoint",
+			"Traceparent":     "",
 
 			"ArtifactsSaveHost":  "save-host, H",
 			"ArtifactsAuthToken": "auth-token, T",
+
+			"ConfigFile": "config, c",
 		},
 		"doc": map[string]string{
 			"AccessKey":    "upload credentials key *REQUIRED*",
@@ -71,17 +122,65 @@ var (
 			"BuildID":     "build id",
 			"JobNumber":   "job number",
 			"JobID":       "job id",
+			"Branch":      "branch being built",
+			"Tag":         "tag being built, if any",
+			"PullRequest": "pull request being built, if any",
+			"Commit":      "commit being built",
+			"OS":          "operating system of the build",
+
+			"Concurrency":   "upload worker concurrency",
+			"MaxSize":       "max combined size of uploaded artifacts",
+			"Paths":         "",
+			"Provider":      "artifact upload providers (artifacts, s3, null; ','-delimited)",
+			"FailurePolicy": "when failed artifacts fail the run (ignore, any, all)",
+			"Retries":       "number of upload retries per artifact",
+			"TargetPaths":   "artifact target paths (':'-delimited), which may be templates like \"artifacts/{{ .Branch | slugify }}\"",
+			"WorkingDir":    "working directory",
+
+			"ContentRules":  "header rules by pattern, e.g. \"*.log?content-type=text/plain\" (':'-delimited)",
+			"MimeTypesFile": "mime.types-style file of content types by extension",
+
+			"Symlinks":    "how to handle symlinks (follow, skip, error, preserve)",
+			"HiddenFiles": "whether to upload dotfiles (include, exclude)",
+
+			"DryRun":       "walk paths and print the upload plan without uploading",
+			"DryRunFormat": "dry run plan format (table, json)",
+
+			"JournalFile": "file recording progress so a re-run skips or resumes uploads",
+			"PartSize":    "multipart upload part size when using a journal or encryption",
+
+			"CAS":       "store each distinct file once as a blob keyed by its SHA-256 (s3 only)",
+			"CASPrefix": "prefix that content-addressed blobs are stored under",
+
+			"Delete":       "delete remote artifacts under the target paths that no longer exist locally (s3 only)",
+			"MaxDeletions": "refuse to delete more than this many stale artifacts in one run",
 
-			"Concurrency": "upload worker concurrency",
-			"MaxSize":     "max combined size of uploaded artifacts",
-			"Paths":       "",
-			"Provider":    "artifact upload provider (artifacts, s3, null)",
-			"Retries":     "number of upload retries per artifact",
-			"TargetPaths": "artifact target paths (':'-delimited)",
-			"WorkingDir":  "working directory",
+			"LatestBranches": ":-delimited branch globs to publish latest aliases for (s3 only)",
+			"LatestTags":     ":-delimited tag globs to publish latest aliases for (s3 only)",
+			"LatestPrefix":   "prefix that latest aliases are published under",
+			"LatestMode":     "how latest aliases are published (copy, redirect)",
+
+			"SummaryMarkdown":   "file to append a Markdown report of the uploaded artifacts to",
+			"SigningKey":        "minisign secret key file, or the key itself, to sign each artifact with",
+			"Checksums":         ":-delimited checksum files to upload to each target path (SHA256SUMS, SHA512SUMS)",
+			"Provenance":        "upload an in-toto provenance statement of the artifacts to each target path",
+			"ProvenanceBuilder": "builder id for the provenance statement (default from the CI detected)",
+
+			"EncryptionPassphrase": "passphrase to encrypt artifacts with before uploading them (s3 only)",
+
+			"ShutdownTimeout":  "seconds to let in-flight uploads finish after SIGINT/SIGTERM",
+			"ProgressInterval": "seconds between progress log entries (0 disables progress)",
+
+			"MetricsFile":    "file to write Prometheus metrics to at the end of the run",
+			"MetricsPushURL": "Pushgateway URL to push Prometheus metrics to",
+
+			"TracingEndpoint": "OTLP/HTTP endpoint to export traces to",
+			"Traceparent":     "",
 
 			"ArtifactsSaveHost":  "artifact save host",
 			"ArtifactsAuthToken": "artifact save auth token",
+
+			"ConfigFile": "config file (default .artifacts.{yml,yaml,toml,json} in working directory)",
 		},
 		"env": map[string]string{
 			"AccessKey":    "ARTIFACTS_KEY,ARTIFACTS_AWS_ACCESS_KEY,AWS_ACCESS_KEY_ID,AWS_ACCESS_KEY",
@@ -96,17 +195,65 @@ var (
 			"BuildID":     "ARTIFACTS_BUILD_ID,TRAVIS_BUILD_ID",
 			"JobNumber":   "ARTIFACTS_JOB_NUMBER,TRAVIS_JOB_NUMBER",
 			"JobID":       "ARTIFACTS_JOB_ID,TRAVIS_JOB_ID",
+			"Branch":      "ARTIFACTS_BRANCH,TRAVIS_BRANCH",
+			"Tag":         "ARTIFACTS_TAG,TRAVIS_TAG",
+			"PullRequest": "ARTIFACTS_PULL_REQUEST,TRAVIS_PULL_REQUEST",
+			"Commit":      "ARTIFACTS_COMMIT,TRAVIS_COMMIT",
+			"OS":          "ARTIFACTS_OS,TRAVIS_OS_NAME",
+
+			"Concurrency":   "ARTIFACTS_CONCURRENCY",
+			"MaxSize":       "ARTIFACTS_MAX_SIZE",
+			"Paths":         "ARTIFACTS_PATHS",
+			"Provider":      "ARTIFACTS_UPLOAD_PROVIDER",
+			"FailurePolicy": "ARTIFACTS_FAILURE_POLICY",
+			"Retries":       "ARTIFACTS_RETRIES",
+			"TargetPaths":   "ARTIFACTS_TARGET_PATHS",
+			"WorkingDir":    "ARTIFACTS_WORKING_DIR,TRAVIS_BUILD_DIR",
+
+			"ContentRules":  "ARTIFACTS_CONTENT_RULES",
+			"MimeTypesFile": "ARTIFACTS_MIME_TYPES_FILE",
+
+			"Symlinks":    "ARTIFACTS_SYMLINKS",
+			"HiddenFiles": "ARTIFACTS_HIDDEN_FILES",
+
+			"DryRun":       "ARTIFACTS_DRY_RUN",
+			"DryRunFormat": "ARTIFACTS_DRY_RUN_FORMAT",
 
-			"Concurrency": "ARTIFACTS_CONCURRENCY",
-			"MaxSize":     "ARTIFACTS_MAX_SIZE",
-			"Paths":       "ARTIFACTS_PATHS",
-			"Provider":    "ARTIFACTS_UPLOAD_PROVIDER",
-			"Retries":     "ARTIFACTS_RETRIES",
-			"TargetPaths": "ARTIFACTS_TARGET_PATHS",
-			"WorkingDir":  "ARTIFACTS_WORKING_DIR,TRAVIS_BUILD_DIR,PWD",
+			"JournalFile": "ARTIFACTS_JOURNAL",
+			"PartSize":    "ARTIFACTS_PART_SIZE",
+
+			"CAS":       "ARTIFACTS_CAS",
+			"CASPrefix": "ARTIFACTS_CAS_PREFIX",
+
+			"Delete":       "ARTIFACTS_DELETE",
+			"MaxDeletions": "ARTIFACTS_MAX_DELETIONS",
+
+			"LatestBranches": "ARTIFACTS_LATEST_BRANCHES",
+			"LatestTags":     "ARTIFACTS_LATEST_TAGS",
+			"LatestPrefix":   "ARTIFACTS_LATEST_PREFIX",
+			"LatestMode":     "ARTIFACTS_LATEST_MODE",
+
+			"SummaryMarkdown":   "ARTIFACTS_SUMMARY_MARKDOWN,GITHUB_STEP_SUMMARY",
+			"SigningKey":        "ARTIFACTS_SIGNING_KEY",
+			"Checksums":         "ARTIFACTS_CHECKSUMS",
+			"Provenance":        "ARTIFACTS_PROVENANCE",
+			"ProvenanceBuilder": "ARTIFACTS_PROVENANCE_BUILDER",
+
+			"EncryptionPassphrase": "ARTIFACTS_ENCRYPTION_PASSPHRASE",
+
+			"ShutdownTimeout":  "ARTIFACTS_SHUTDOWN_TIMEOUT",
+			"ProgressInterval": "ARTIFACTS_PROGRESS_INTERVAL",
+
+			"MetricsFile":    "ARTIFACTS_METRICS_FILE",
+			"MetricsPushURL": "ARTIFACTS_METRICS_PUSH_URL",
+
+			"TracingEndpoint": "ARTIFACTS_TRACING_ENDPOINT,OTEL_EXPORTER_OTLP_ENDPOINT",
+			"Traceparent":     "ARTIFACTS_TRACEPARENT,TRACEPARENT",
 
 			"ArtifactsSaveHost":  "ARTIFACTS_SAVE_HOST",
 			"ArtifactsAuthToken": "ARTIFACTS_AUTH_TOKEN",
+
+			"ConfigFile": "ARTIFACTS_CONFIG",
 		},
 		"default": map[string]string{
 			"AccessKey":    "",
@@ -121,17 +268,138 @@ var (
 			"BuildID":     "",
 			"JobNumber":   "",
 			"JobID":       "",
+			"Branch":      "",
+			"Tag":         "",
+			"PullRequest": "false",
+			"Commit":      "",
+			"OS":          runtime.GOOS,
+
+			"Concurrency":   "5",
+			"MaxSize":       fmt.Sprintf("%d", 1024*1024*1000),
+			"Paths":         "",
+			"Provider":      "s3",
+			"FailurePolicy": "ignore",
+			"Retries":       "2",
+			"TargetPaths":   "artifacts/{{ .BuildNumber }}/{{ .JobNumber }}",
+			"WorkingDir":    "$PWD",
 
-			"Concurrency": "5",
-			"MaxSize":     fmt.Sprintf("%d", 1024*1024*1000),
-			"Paths":       "",
-			"Provider":    "s3",
-			"Retries":     "2",
-			"TargetPaths": "artifacts/$TRAVIS_BUILD_NUMBER/$TRAVIS_JOB_NUMBER",
-			"WorkingDir":  ".",
+			"ContentRules":  "",
+			"MimeTypesFile": "",
+
+			"Symlinks":    "follow",
+			"HiddenFiles": "include",
+
+			"DryRun":       "false",
+			"DryRunFormat": "table",
+
+			"JournalFile": "",
+			"PartSize":    fmt.Sprintf("%d", 1024*1024*16),
+
+			"CAS":       "false",
+			"CASPrefix": "artifacts/cas",
+
+			"Delete":       "false",
+			"MaxDeletions": "1000",
+
+			"LatestBranches": "",
+			"LatestTags":     "",
+			"LatestPrefix":   "latest",
+			"LatestMode":     "copy",
+
+			"SummaryMarkdown":   "",
+			"SigningKey":        "",
+			"Checksums":         "",
+			"Provenance":        "false",
+			"ProvenanceBuilder": "",
+
+			"EncryptionPassphrase": "",
+
+			"ShutdownTimeout":  "10",
+			"ProgressInterval": "10",
+
+			"MetricsFile":    "",
+			"MetricsPushURL": "",
+
+			"TracingEndpoint": "",
+			"Traceparent":     "",
 
 			"ArtifactsSaveHost":  "",
 			"ArtifactsAuthToken": "",
+
+			"ConfigFile": "",
+		},
+		"config": map[string]string{
+			"AccessKey":    "key",
+			"BucketName":   "bucket",
+			"CacheControl": "cache-control",
+			"Perm":         "permissions",
+			"SecretKey":    "secret",
+			"S3Region":     "s3-region",
+
+			"RepoSlug":    "repo-slug",
+			"BuildNumber": "build-number",
+			"BuildID":     "build-id",
+			"JobNumber":   "job-number",
+			"JobID":       "job-id",
+			"Branch":      "branch",
+			"Tag":         "tag",
+			"PullRequest": "pull-request",
+			"Commit":      "commit",
+			"OS":          "os",
+
+			"Concurrency":   "concurrency",
+			"MaxSize":       "max-size",
+			"Paths":         "paths",
+			"Provider":      "upload-provider",
+			"FailurePolicy": "failure-policy",
+			"Retries":       "retries",
+			"TargetPaths":   "target-paths",
+			"WorkingDir":    "working-dir",
+
+			"ContentRules":  "content-rules",
+			"MimeTypesFile": "mime-types-file",
+
+			"Symlinks":    "symlinks",
+			"HiddenFiles": "hidden-files",
+
+			"DryRun":       "dry-run",
+			"DryRunFormat": "dry-run-format",
+
+			"JournalFile": "journal",
+			"PartSize":    "part-size",
+
+			"CAS":       "cas",
+			"CASPrefix": "cas-prefix",
+
+			"Delete":       "delete",
+			"MaxDeletions": "max-deletions",
+
+			"LatestBranches": "latest-branches",
+			"LatestTags":     "latest-tags",
+			"LatestPrefix":   "latest-prefix",
+			"LatestMode":     "latest-mode",
+
+			"SummaryMarkdown":   "summary-markdown",
+			"SigningKey":        "signing-key",
+			"Checksums":         "checksums",
+			"Provenance":        "provenance",
+			"ProvenanceBuilder": "provenance-builder",
+
+			"EncryptionPassphrase": "encryption-passphrase",
+
+			"ShutdownTimeout":  "shutdown-timeout",
+			"ProgressInterval": "progress-interval",
+
+			"MetricsFile":    "metrics-file",
+			"MetricsPushURL": "metrics-push-url",
+
+			"TracingEndpoint": "tracing-endpoint",
+			"Traceparent":     "traceparent",
+
+			"ArtifactsSaveHost":  "save-host",
+			"ArtifactsAuthToken": "auth-token",
+
+			"ConfigFile": "",
 		},
 	}
 )
@@ -150,17 +418,73 @@ type Options struct {
 	BuildID     string
 	JobNumber   string
 	JobID       string
+	Branch      string
+	Tag         string
+	PullRequest string
+	Commit      string
+	OS          string
+
+	Concurrency   uint64
+	MaxSize       uint64
+	Paths         []string
+	Provider      string
+	FailurePolicy string
+	Retries       uint64
+	TargetPaths   []string
+	WorkingDir    string
+
+	ContentRules  []string
+	MimeTypesFile string
+
+	Symlinks    string
+	HiddenFiles string
 
-	Concurrency uint64
-	MaxSize     uint64
-	Paths       []string
-	Provider    string
-	Retries     uint64
-	TargetPaths []string
-	WorkingDir  string
+	DryRun       bool
+	DryRunFormat string
+
+	JournalFile string
+	PartSize    uint64
+
+	CAS       bool
+	CASPrefix string
+
+	Delete       bool
+	MaxDeletions uint64
+
+	LatestBranches []string
+	LatestTags     []string
+	LatestPrefix   string
+	LatestMode     string
+
+	SummaryMarkdown string
+	SigningKey      string
+	Checksums       []string
+
+	Provenance        bool
+	ProvenanceBuilder string
+
+	EncryptionPassphrase string
+
+	ShutdownTimeout  uint64
+	ProgressInterval uint64
+
+	MetricsFile    string
+	MetricsPushURL string
+
+	TracingEndpoint string
+	Traceparent     string
 
 	ArtifactsSaveHost  string
 	ArtifactsAuthToken string
+
+	ConfigFile string
+
+	// detectedCI is the name of the CI provider detected, if any
+	detectedCI string
+
+	// explicit records which options were set via env or CLI, and
+	// so take precedence over the config file
+	explicit map[string]bool
 }
 
 // NewOptions makes some *Options with defaults!
@@ -189,6 +513,15 @@ func (opts *Options) Flags() []cli.Flag {
 			continue
 		}
 
+		if f.Kind() == reflect.Bool {
+			flags = append(flags, cli.BoolFlag{
+				Name:   name,
+				EnvVar: strings.Split(optsMaps["env"][tf.Name], ",")[0],
+				Usage:  optsMaps["doc"][tf.Name],
+			})
+			continue
+		}
+
 		flags = append(flags, cli.StringFlag{
 			Name:   name,
 			EnvVar: strings.Split(optsMaps["env"][tf.Name], ",")[0],
@@ -202,6 +535,13 @@ func (opts *Options) Flags() []cli.Flag {
 }
 
 func (opts *Options) reset() {
+	opts.explicit = map[string]bool{}
+
+	detected := ci.Detect()
+	if detected != nil {
+		opts.detectedCI = detected.Name
+	}
+
 	s := reflect.ValueOf(opts).Elem()
 	t := s.Type()
 
@@ -216,12 +556,22 @@ func (opts *Options) reset() {
 		envKeys := strings.Split(optsMaps["env"][tf.Name], ",")
 		value, envVar := env.CascadeMatch(envKeys, dflt)
 
+		// the detected CI's own vars only fill in what the ARTIFACTS_*
+		// and TRAVIS_* vars did not
+		fromCI := false
+		if ciValue := detected.Value(tf.Name); envVar == "" && ciValue != "" && f.Kind() == reflect.String {
+			value = ciValue
+			fromCI = true
+		}
+
 		if value == "" {
 			continue
 		}
 
-		if envVar == "" {
+		if envVar == "" && !fromCI {
 			envVar = envKeys[0]
+		} else {
+			opts.explicit[tf.Name] = true
 		}
 
 		value = os.ExpandEnv(value)
@@ -238,8 +588,15 @@ func (opts *Options) reset() {
 				f.SetUint(env.Uint(envVar, uintVal))
 			}
 		case reflect.Slice:
-			sliceValue := env.Slice(envVar, ":", strings.Split(":", dflt))
+			sliceValue := env.Slice(envVar, ":", strings.Split(dflt, ":"))
 			f.Set(reflect.ValueOf(sliceValue))
+		case reflect.Bool:
+			boolVal, err := strconv.ParseBool(dflt)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v", err)
+			} else {
+				f.SetBool(env.Bool(envVar, boolVal))
+			}
 		default:
 			panic(fmt.Sprintf("unknown kind wat: %v", k))
 		}
@@ -266,38 +623,41 @@ func (opts *Options) UpdateFromCLI(c *cli.Context) {
 		}
 
 		name := nameParts[0]
+		if f.Kind() == reflect.Bool {
+			if c.Bool(name) {
+				f.SetBool(true)
+				opts.explicit[tf.Name] = true
+			}
+			continue
+		}
+
 		value := c.String(name)
 		if value == "" {
 			continue
 		}
 
+		opts.explicit[tf.Name] = true
+
 		switch name {
-		case "concurrency", "retries":
+		case "concurrency", "retries", "shutdown-timeout", "progress-interval", "max-deletions":
 			intVal, err := strconv.ParseUint(value, 10, 64)
 			if err == nil {
 				f.SetUint(intVal)
 			}
-		case "max-size":
-			if strings.ContainsAny(value, sizeChars) {
-				b, err := humanize.ParseBytes(value)
-				if err == nil {
-					opts.MaxSize = b
-				}
-			} else {
-				intVal, err := strconv.ParseUint(value, 10, 64)
-				if err == nil {
-					opts.MaxSize = intVal
-				}
+		case "max-size", "part-size":
+			b, err := parseSize(value)
+			if err == nil {
+				f.SetUint(b)
 			}
-		case "target-paths":
-			tp := []string{}
+		case "target-paths", "content-rules", "latest-branches", "latest-tags", "checksums":
+			list := []string{}
 			for _, part := range strings.Split(value, ":") {
 				trimmed := strings.TrimSpace(part)
 				if trimmed != "" {
-					tp = append(tp, trimmed)
+					list = append(list, trimmed)
 				}
 			}
-			opts.TargetPaths = tp
+			f.Set(reflect.ValueOf(list))
 		default:
 			if f.Kind() == reflect.String {
 				f.SetString(value)
@@ -307,18 +667,119 @@ func (opts *Options) UpdateFromCLI(c *cli.Context) {
 
 	for _, arg := range c.Args() {
 		opts.Paths = append(opts.Paths, arg)
+		opts.explicit["Paths"] = true
+	}
+}
+
+func parseSize(value string) (uint64, error) {
+	if strings.ContainsAny(value, sizeChars) {
+		return humanize.ParseBytes(value)
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// ProviderNames returns the names of all upload providers given
+func (opts *Options) ProviderNames() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(opts.Provider, ",") {
+		name := strings.TrimSpace(part)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		names = append(names, "s3")
+	}
+
+	return names
+}
+
+// Secrets returns the credentials and tokens, for redacting them from
+// the logs
+func (opts *Options) Secrets() []string {
+	return []string{
+		opts.AccessKey, opts.SecretKey, opts.ArtifactsAuthToken,
+		opts.SigningKey, opts.EncryptionPassphrase,
 	}
 }
 
 // Validate checks for validity!
 func (opts *Options) Validate() error {
-	if opts.Provider == "s3" {
+	if opts.FailurePolicy != "" && !failurePolicies[opts.FailurePolicy] {
+		return fmt.Errorf("invalid failure policy %q", opts.FailurePolicy)
+	}
+
+	if opts.Symlinks != "" && !symlinkPolicies[opts.Symlinks] {
+		return fmt.Errorf("invalid symlinks policy %q", opts.Symlinks)
+	}
+
+	if opts.HiddenFiles != "" && !hiddenFilesPolicies[opts.HiddenFiles] {
+		return fmt.Errorf("invalid hidden files policy %q", opts.HiddenFiles)
+	}
+
+	if opts.DryRunFormat != "" && !planFormats[opts.DryRunFormat] {
+		return fmt.Errorf("invalid dry run format %q", opts.DryRunFormat)
+	}
+
+	if err := opts.validateTemplates(); err != nil {
+		return err
+	}
+
+	if _, err := opts.LoadContentRules(); err != nil {
+		return err
+	}
+
+	if (opts.JournalFile != "" || opts.EncryptionPassphrase != "") && opts.PartSize < minPartSize {
+		return fmt.Errorf("part size must be at least 5MiB")
+	}
+
+	if opts.Delete && !opts.hasProvider("s3") {
+		return fmt.Errorf("delete is only supported by the s3 provider")
+	}
+
+	if err := opts.validateLatest(); err != nil {
+		return err
+	}
+
+	if err := opts.validateSigning(); err != nil {
+		return err
+	}
+
+	for _, name := range opts.Checksums {
+		if _, ok := checksumAlgorithms[name]; !ok {
+			return fmt.Errorf("invalid checksum file %q", name)
+		}
+	}
+
+	if err := opts.validateEncryption(); err != nil {
+		return err
+	}
+
+	if opts.DryRun && !opts.Delete {
+		// nothing is uploaded or listed, so no credentials are needed
+		return nil
+	}
+
+	if opts.hasProvider("s3") {
 		return opts.validateS3()
 	}
 
 	return nil
 }
 
+func (opts *Options) hasProvider(provider string) bool {
+	for _, name := range opts.ProviderNames() {
+		if name == provider {
+			return true
+		}
+	}
+	return false
+}
+
 func (opts *Options) validateS3() error {
 	if opts.BucketName == "" {
 		return fmt.Errorf("no bucket name given")
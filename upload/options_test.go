@@ -54,3 +54,118 @@ func TestOptionsValidateS3(t *testing.T) {
 		t.Fatalf("valid s3 options were deemed invalid")
 	}
 }
+
+func TestOptionsValidateFailurePolicy(t *testing.T) {
+	os.Clearenv()
+	opts := NewOptions()
+	opts.Provider = "null"
+	opts.FailurePolicy = "sometimes"
+
+	if opts.Validate() == nil {
+		t.Fatalf("invalid failure policy was valid")
+	}
+}
+
+func TestOptionsValidateMultipleProvidersS3(t *testing.T) {
+	os.Clearenv()
+	opts := NewOptions()
+	opts.Provider = "artifacts,s3"
+
+	if opts.Validate() == nil {
+		t.Fatalf("s3 destination without bucket was valid")
+	}
+}
+
+func TestOptionsValidatePathSettings(t *testing.T) {
+	os.Clearenv()
+	opts := NewOptions()
+	opts.Provider = "null"
+	opts.Paths = []string{"site/?permissions=public-read"}
+
+	if opts.Validate() != nil {
+		t.Fatalf("valid path settings were invalid")
+	}
+
+	opts.Paths = []string{"site/?permissions=%zz"}
+	if opts.Validate() == nil {
+		t.Fatalf("badly escaped path setting was valid")
+	}
+}
+
+func TestOptionsDryRunFromEnv(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("ARTIFACTS_DRY_RUN", "true")
+	defer os.Clearenv()
+
+	opts := NewOptions()
+	if !opts.DryRun {
+		t.Fatalf("dry run not set from env")
+	}
+
+	opts.DryRunFormat = "yaml"
+	if opts.Validate() == nil {
+		t.Fatalf("invalid dry run format was valid")
+	}
+}
+
+func TestOptionsDetectedCI(t *testing.T) {
+	setenvs(map[string]string{
+		"GITHUB_ACTIONS":       "true",
+		"GITHUB_REPOSITORY":    "owner/repo",
+		"GITHUB_RUN_NUMBER":    "12",
+		"GITHUB_JOB":           "test",
+		"ARTIFACTS_REPO_SLUG":  "owner/fork",
+		"TRAVIS_REPO_SLUG":     "",
+		"TRAVIS_BUILD_NUMBER":  "",
+		"ARTIFACTS_JOB_NUMBER": "",
+		"TRAVIS_JOB_NUMBER":    "",
+		"TRAVIS":               "",
+	})
+	defer setenvs(map[string]string{
+		"GITHUB_ACTIONS":      "",
+		"GITHUB_REPOSITORY":   "",
+		"GITHUB_RUN_NUMBER":   "",
+		"GITHUB_JOB":          "",
+		"ARTIFACTS_REPO_SLUG": "",
+	})
+
+	opts := NewOptions()
+	if opts.detectedCI != "github-actions" {
+		t.Fatalf("%v != github-actions", opts.detectedCI)
+	}
+
+	if opts.RepoSlug != "owner/fork" {
+		t.Fatalf("%v != owner/fork", opts.RepoSlug)
+	}
+
+	if opts.BuildNumber != "12" || opts.JobNumber != "test" {
+		t.Fatalf("unexpected build %v and job %v", opts.BuildNumber, opts.JobNumber)
+	}
+
+	u := newUploader(opts, getPanicLogger())
+	if len(u.Opts.TargetPaths) != 1 || u.Opts.TargetPaths[0] != "artifacts/12/test" {
+		t.Fatalf("unexpected target paths %v", u.Opts.TargetPaths)
+	}
+}
+
+func TestOptionsValidateEncryption(t *testing.T) {
+	os.Clearenv()
+	opts := NewOptions()
+	opts.Provider = "null"
+	opts.EncryptionPassphrase = "hunter2"
+
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("encryption was invalid: %v", err)
+	}
+
+	opts.CAS = true
+	if opts.Validate() == nil {
+		t.Fatalf("encryption with CAS was valid")
+	}
+
+	opts.CAS = false
+	opts.Provider = "null,artifacts"
+	if opts.Validate() == nil {
+		t.Fatalf("encryption with the artifacts provider was valid")
+	}
+}
@@ -0,0 +1,208 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/travis-ci/artifacts/artifact"
+)
+
+const (
+	// PlanFormatTable prints the dry run plan as a table
+	PlanFormatTable = "table"
+	// PlanFormatJSON prints the dry run plan as JSON
+	PlanFormatJSON = "json"
+)
+
+var (
+	planFormats = map[string]bool{
+		PlanFormatTable: true,
+		PlanFormatJSON:  true,
+	}
+)
+
+type planEntry struct {
+	Source      string `json:"source"`
+	Dest        string `json:"dest"`
+	Provider    string `json:"provider"`
+	Size        uint64 `json:"size"`
+	ContentType string `json:"content_type"`
+	Permissions string `json:"permissions"`
+}
+
+// uploadPlan is what a dry run would have uploaded
+type uploadPlan struct {
+	sync.Mutex
+
+	Artifacts  []*planEntry `json:"artifacts"`
+	TotalCount int          `json:"total_count"`
+	TotalSize  uint64       `json:"total_size"`
+	MaxSize    uint64       `json:"max_size"`
+	Deletions  []string     `json:"deletions,omitempty"`
+	Latest     string       `json:"latest,omitempty"`
+	Checksums  []string     `json:"checksums,omitempty"`
+	Provenance []string     `json:"provenance,omitempty"`
+
+	dests map[string]bool
+}
+
+func newUploadPlan(maxSize uint64) *uploadPlan {
+	return &uploadPlan{
+		Artifacts: []*planEntry{},
+		MaxSize:   maxSize,
+		dests:     map[string]bool{},
+	}
+}
+
+// Add records an artifact, counting its size once per destination path
+// no matter how many providers it would go to
+func (p *uploadPlan) Add(a *artifact.Artifact) {
+	size, _ := a.Size()
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.Artifacts = append(p.Artifacts, &planEntry{
+		Source:      a.Source,
+		Dest:        a.FullDest(),
+		Provider:    a.UploadResult.Provider,
+		Size:        size,
+		ContentType: a.ContentType(),
+		Permissions: string(a.Perm),
+	})
+
+	if !p.dests[a.FullDest()] {
+		p.dests[a.FullDest()] = true
+		p.TotalCount++
+		p.TotalSize += size
+	}
+}
+
+// Write prints the plan in the given format
+func (p *uploadPlan) Write(w io.Writer, format string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	sort.Sort(planEntries(p.Artifacts))
+
+	if format == PlanFormatJSON {
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tDEST\tPROVIDER\tSIZE\tCONTENT TYPE\tPERMISSIONS")
+	for _, e := range p.Artifacts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Source, e.Dest, e.Provider, humanize.Bytes(e.Size), e.ContentType, e.Permissions)
+	}
+
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "\n%d artifacts, %s total (max size %s)\n",
+		p.TotalCount, humanize.Bytes(p.TotalSize), humanize.Bytes(p.MaxSize))
+	if err != nil {
+		return err
+	}
+
+	if p.Latest != "" {
+		fmt.Fprintf(w, "\nlatest aliases would be published under %s\n", p.Latest)
+	}
+
+	if len(p.Checksums) > 0 {
+		fmt.Fprintf(w, "\nchecksum files would be uploaded:\n")
+		for _, key := range p.Checksums {
+			fmt.Fprintf(w, "  %s\n", key)
+		}
+	}
+
+	if len(p.Provenance) > 0 {
+		fmt.Fprintf(w, "\nprovenance would be uploaded:\n")
+		for _, key := range p.Provenance {
+			fmt.Fprintf(w, "  %s\n", key)
+		}
+	}
+
+	if len(p.Deletions) > 0 {
+		fmt.Fprintf(w, "\n%d stale artifacts would be deleted:\n", len(p.Deletions))
+		for _, key := range p.Deletions {
+			fmt.Fprintf(w, "  %s\n", key)
+		}
+	}
+
+	return nil
+}
+
+type planEntries []*planEntry
+
+func (pe planEntries) Len() int      { return len(pe) }
+func (pe planEntries) Swap(i, j int) { pe[i], pe[j] = pe[j], pe[i] }
+func (pe planEntries) Less(i, j int) bool {
+	if pe[i].Source != pe[j].Source {
+		return pe[i].Source < pe[j].Source
+	}
+	if pe[i].Dest != pe[j].Dest {
+		return pe[i].Dest < pe[j].Dest
+	}
+	return pe[i].Provider < pe[j].Provider
+}
+
+// dryRun walks the paths like a real upload would, then prints the
+// plan instead of handing the artifacts to the providers
+func (u *uploader) dryRun() error {
+	u.log.WithField("providers", u.providerNames()).Info("dry run, nothing will be uploaded")
+
+	plan := newUploadPlan(u.Opts.MaxSize)
+	var wg sync.WaitGroup
+
+	for _, ch := range u.files() {
+		wg.Add(1)
+		go func(ch chan *artifact.Artifact) {
+			defer wg.Done()
+			for a := range ch {
+				u.inFlight.Remove(a)
+				plan.Add(a)
+			}
+		}(ch)
+	}
+
+	wg.Wait()
+
+	if u.feedErr != nil {
+		return u.feedErr
+	}
+
+	if u.mirror != nil {
+		stale, err := u.staleKeys()
+		if stale == nil {
+			return err
+		}
+		if err != nil {
+			u.log.Warn(err)
+		}
+		plan.Deletions = stale
+	}
+
+	if u.latest != nil {
+		plan.Latest = u.Opts.latestPrefix()
+	}
+
+	if u.checksums != nil {
+		plan.Checksums = u.checksumDests()
+		plan.Provenance = u.provenanceDests()
+	}
+
+	return plan.Write(u.planOut, u.Opts.DryRunFormat)
+}
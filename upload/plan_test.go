@@ -0,0 +1,55 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUploaderDryRun(t *testing.T) {
+	for _, format := range []string{PlanFormatTable, PlanFormatJSON} {
+		opts := NewOptions()
+		opts.DryRun = true
+		opts.DryRunFormat = format
+		opts.Paths = []string{testArtifactPathDir}
+		opts.TargetPaths = []string{"artifacts/1", "artifacts/latest"}
+
+		rp := &recordingProvider{name: "ok"}
+		out := &bytes.Buffer{}
+
+		u := newUploader(opts, getPanicLogger())
+		u.Providers = []uploadProvider{rp}
+		u.planOut = out
+
+		err := u.Upload()
+		if err != nil {
+			t.Fatalf("dry run failed: %v", err)
+		}
+
+		if len(rp.uploaded) != 0 {
+			t.Fatalf("dry run uploaded %v artifacts", len(rp.uploaded))
+		}
+
+		if format == PlanFormatTable {
+			if !strings.Contains(out.String(), "artifacts/latest/") {
+				t.Fatalf("plan missing target path: %q", out.String())
+			}
+			continue
+		}
+
+		plan := &uploadPlan{}
+		err = json.Unmarshal(out.Bytes(), plan)
+		if err != nil {
+			t.Fatalf("plan is not JSON: %v", err)
+		}
+
+		if plan.TotalCount == 0 || plan.TotalCount != len(plan.Artifacts) {
+			t.Fatalf("total count %v != %v", plan.TotalCount, len(plan.Artifacts))
+		}
+
+		if !strings.HasPrefix(plan.Artifacts[0].Dest, "artifacts/") {
+			t.Fatalf("unexpected dest %v", plan.Artifacts[0].Dest)
+		}
+	}
+}
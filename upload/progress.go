@@ -0,0 +1,286 @@
+package upload
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dustin/go-humanize"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/logging"
+)
+
+const (
+	progressBarWidth    = 30
+	progressBarInterval = 500 * time.Millisecond
+)
+
+type progressTracker struct {
+	sync.Mutex
+
+	TotalBytes uint64
+	SentBytes  uint64
+	TotalFiles uint64
+	DoneFiles  uint64
+
+	startTime time.Time
+}
+
+type progressSnapshot struct {
+	TotalBytes uint64
+	SentBytes  uint64
+	TotalFiles uint64
+	DoneFiles  uint64
+	Rate       float64
+	ETA        time.Duration
+}
+
+type countingReader struct {
+	r       io.Reader
+	tracker *progressTracker
+	counted *uint64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	if n > 0 {
+		cr.tracker.Lock()
+		cr.tracker.SentBytes += uint64(n)
+		*cr.counted += uint64(n)
+		cr.tracker.Unlock()
+	}
+	return n, err
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{startTime: time.Now()}
+}
+
+// AddArtifact counts the artifact towards the totals and wraps its
+// reader so that bytes sent are tracked.  Bytes counted by a previous
+// reader (i.e. before a retry) are discounted when a new one is made.
+func (pt *progressTracker) AddArtifact(a *artifact.Artifact, size uint64) {
+	pt.Lock()
+	defer pt.Unlock()
+
+	pt.TotalBytes += size
+	pt.TotalFiles++
+
+	counted := uint64(0)
+	a.AddReaderWrapper(func(r io.Reader) io.Reader {
+		pt.Lock()
+		pt.SentBytes -= counted
+		counted = 0
+		pt.Unlock()
+
+		return &countingReader{r: r, tracker: pt, counted: &counted}
+	})
+}
+
+// Done marks an artifact as finished, whether or not it succeeded
+func (pt *progressTracker) Done(a *artifact.Artifact) {
+	pt.Lock()
+	defer pt.Unlock()
+
+	pt.DoneFiles++
+}
+
+func (pt *progressTracker) Snapshot() *progressSnapshot {
+	pt.Lock()
+	defer pt.Unlock()
+
+	snap := &progressSnapshot{
+		TotalBytes: pt.TotalBytes,
+		SentBytes:  pt.SentBytes,
+		TotalFiles: pt.TotalFiles,
+		DoneFiles:  pt.DoneFiles,
+	}
+
+	elapsed := time.Since(pt.startTime).Seconds()
+	if elapsed > 0 {
+		snap.Rate = float64(snap.SentBytes) / elapsed
+	}
+
+	if snap.Rate > 0 && snap.TotalBytes > snap.SentBytes {
+		remaining := float64(snap.TotalBytes-snap.SentBytes) / snap.Rate
+		snap.ETA = time.Duration(remaining) * time.Second
+	}
+
+	return snap
+}
+
+func (ps *progressSnapshot) Percent() float64 {
+	if ps.TotalBytes == 0 {
+		return float64(0)
+	}
+	return pctMax(ps.SentBytes, ps.TotalBytes)
+}
+
+func (ps *progressSnapshot) Fields() logrus.Fields {
+	return logrus.Fields{
+		"bytes_sent":  humanize.Bytes(ps.SentBytes),
+		"bytes_total": humanize.Bytes(ps.TotalBytes),
+		"files_done":  ps.DoneFiles,
+		"files_total": ps.TotalFiles,
+		"percent":     fmt.Sprintf("%.1f", ps.Percent()),
+		"rate":        fmt.Sprintf("%s/s", humanize.Bytes(uint64(ps.Rate))),
+		"eta":         ps.ETA,
+	}
+}
+
+func (ps *progressSnapshot) Bar() string {
+	filled := int(ps.Percent() / 100.0 * progressBarWidth)
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+
+	return fmt.Sprintf("[%s%s] %5.1f%% %s/%s %d/%d files %s/s ETA %v",
+		strings.Repeat("=", filled),
+		strings.Repeat(" ", progressBarWidth-filled),
+		ps.Percent(),
+		humanize.Bytes(ps.SentBytes),
+		humanize.Bytes(ps.TotalBytes),
+		ps.DoneFiles, ps.TotalFiles,
+		humanize.Bytes(uint64(ps.Rate)),
+		ps.ETA)
+}
+
+// progressReporter periodically emits the state of a progressTracker,
+// either as a redrawn progress bar when logging to a terminal with the
+// text formatter or as log entries otherwise
+type progressReporter struct {
+	tracker  *progressTracker
+	log      *logrus.Logger
+	interval time.Duration
+	isTTY    bool
+	bar      *progressBarWriter
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newProgressReporter(tracker *progressTracker, log *logrus.Logger, interval time.Duration) *progressReporter {
+	return &progressReporter{
+		tracker:  tracker,
+		log:      log,
+		interval: interval,
+		isTTY:    isTextFormatter(log.Formatter) && isTerminal(log.Out),
+
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// isTextFormatter tells whether entries come out as plain text, which
+// a progress bar can be drawn in between, even if secrets are redacted
+func isTextFormatter(f logrus.Formatter) bool {
+	_, ok := logging.Unwrap(f).(*logrus.TextFormatter)
+	return ok
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (pr *progressReporter) Start() {
+	interval := pr.interval
+	if pr.isTTY {
+		interval = progressBarInterval
+		pr.bar = &progressBarWriter{out: pr.log.Out}
+		pr.log.Out = pr.bar
+	}
+
+	go func() {
+		defer close(pr.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				pr.report()
+			case <-pr.stop:
+				pr.report()
+				if pr.bar != nil {
+					pr.bar.Finish()
+				}
+				return
+			}
+		}
+	}()
+}
+
+func (pr *progressReporter) Stop() {
+	close(pr.stop)
+	<-pr.done
+
+	if pr.bar != nil {
+		pr.log.Out = pr.bar.out
+	}
+}
+
+func (pr *progressReporter) report() {
+	snap := pr.tracker.Snapshot()
+	if pr.bar != nil {
+		pr.bar.Draw(snap.Bar())
+		return
+	}
+
+	pr.log.WithFields(snap.Fields()).Info("upload progress")
+}
+
+// progressBarWriter stands in for the log's output while a progress bar
+// is drawn, clearing the bar before each log entry and drawing it again
+// after, under one lock so that entries and the bar never interleave
+type progressBarWriter struct {
+	sync.Mutex
+	out io.Writer
+	bar string
+}
+
+func (w *progressBarWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	fmt.Fprint(w.out, "\r\033[K")
+	n, err := w.out.Write(p)
+	if w.bar != "" {
+		fmt.Fprint(w.out, w.bar)
+	}
+	return n, err
+}
+
+// Draw replaces the progress bar
+func (w *progressBarWriter) Draw(bar string) {
+	w.Lock()
+	defer w.Unlock()
+
+	w.bar = bar
+	fmt.Fprintf(w.out, "\r\033[K%s", bar)
+}
+
+// Finish leaves the last bar drawn on its own line
+func (w *progressBarWriter) Finish() {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.bar != "" {
+		fmt.Fprintln(w.out)
+	}
+	w.bar = ""
+}
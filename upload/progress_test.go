@@ -0,0 +1,130 @@
+package upload
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/logging"
+)
+
+func TestProgressTrackerCountsBytes(t *testing.T) {
+	pt := newProgressTracker()
+	a := artifact.New("bucket", testArtifactPaths[0].Path, "linux/foo", &artifact.Options{
+		Perm:     s3.PublicRead,
+		RepoSlug: "owner/foo",
+	})
+
+	size, _ := a.Size()
+	pt.AddArtifact(a, size)
+
+	for i := 0; i < 2; i++ {
+		reader, err := a.Reader()
+		if err != nil {
+			t.Fatalf("error getting reader: %v", err)
+		}
+
+		_, err = ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("error reading: %v", err)
+		}
+	}
+
+	pt.Done(a)
+
+	snap := pt.Snapshot()
+	if snap.SentBytes != size {
+		t.Fatalf("sent bytes %v != %v", snap.SentBytes, size)
+	}
+
+	if snap.TotalBytes != size {
+		t.Fatalf("total bytes %v != %v", snap.TotalBytes, size)
+	}
+
+	if snap.DoneFiles != 1 || snap.TotalFiles != 1 {
+		t.Fatalf("files %v/%v != 1/1", snap.DoneFiles, snap.TotalFiles)
+	}
+
+	if snap.Percent() != float64(100) {
+		t.Fatalf("percent %v != 100", snap.Percent())
+	}
+}
+
+func TestProgressSnapshotBar(t *testing.T) {
+	snap := &progressSnapshot{
+		TotalBytes: 200,
+		SentBytes:  100,
+		TotalFiles: 4,
+		DoneFiles:  2,
+	}
+
+	bar := snap.Bar()
+	if !strings.HasPrefix(bar, "["+strings.Repeat("=", progressBarWidth/2)+" ") {
+		t.Fatalf("unexpected bar %q", bar)
+	}
+
+	if !strings.Contains(bar, "2/4 files") {
+		t.Fatalf("bar %q missing file counts", bar)
+	}
+}
+
+func TestProgressReporterLogsWhenNotTTY(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := logrus.New()
+	log.Out = out
+
+	pr := newProgressReporter(newProgressTracker(), log, 0)
+	if pr.isTTY {
+		t.Fatalf("reporter writing to buffer thinks it is a TTY")
+	}
+
+	pr.report()
+	if !strings.Contains(out.String(), "upload progress") {
+		t.Fatalf("progress not logged: %q", out.String())
+	}
+}
+
+func TestProgressReporterDrawsBarBetweenEntries(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := logrus.New()
+	log.Out = out
+
+	pr := newProgressReporter(newProgressTracker(), log, 0)
+	pr.isTTY = true
+	pr.Start()
+
+	if log.Out == io.Writer(out) {
+		t.Fatalf("log output not replaced while drawing the bar")
+	}
+
+	pr.bar.Draw("[bar]")
+	log.Out.Write([]byte("entry\n"))
+	pr.Stop()
+
+	if log.Out != io.Writer(out) {
+		t.Fatalf("log output not restored once stopped")
+	}
+
+	if !strings.HasPrefix(out.String(), "\r\033[K[bar]\r\033[Kentry\n[bar]") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	if !strings.HasSuffix(out.String(), "\n") {
+		t.Fatalf("last bar not finished with a newline: %q", out.String())
+	}
+}
+
+func TestIsTextFormatterWhenRedacting(t *testing.T) {
+	if !isTextFormatter(logging.NewRedactingFormatter(&logrus.TextFormatter{})) {
+		t.Fatalf("redacted text formatter is not text")
+	}
+
+	if isTextFormatter(logging.NewRedactingFormatter(&logrus.JSONFormatter{})) {
+		t.Fatalf("redacted JSON formatter is text")
+	}
+}
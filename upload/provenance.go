@@ -0,0 +1,177 @@
+package upload
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/travis-ci/artifacts/signing"
+)
+
+const (
+	provenanceName        = "provenance.intoto.json"
+	provenanceContentType = "application/vnd.in-toto+json"
+
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaPredicateType   = "https://slsa.dev/provenance/v1"
+	provenanceBuildType = "https://github.com/travis-ci/artifacts/upload@v1"
+	provenanceBuilderCI = "https://github.com/travis-ci/artifacts/ci/"
+)
+
+var (
+	// digest names of the checksums, as in-toto spells them
+	provenanceDigests = map[string]string{
+		"SHA256SUMS": "sha256",
+		"SHA512SUMS": "sha512",
+	}
+)
+
+type inTotoStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []*inTotoSubject     `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     *provenancePredicate `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type provenancePredicate struct {
+	BuildDefinition struct {
+		BuildType          string            `json:"buildType"`
+		ExternalParameters map[string]string `json:"externalParameters"`
+		InternalParameters map[string]string `json:"internalParameters"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Metadata struct {
+			InvocationID string `json:"invocationId,omitempty"`
+			StartedOn    string `json:"startedOn"`
+			FinishedOn   string `json:"finishedOn"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// checksumNames are the checksums to collect, for the checksum files
+// and the provenance subjects, which need at least SHA-256
+func (opts *Options) checksumNames() []string {
+	names := append([]string{}, opts.Checksums...)
+	if !opts.Provenance {
+		return names
+	}
+
+	for _, name := range names {
+		if name == "SHA256SUMS" {
+			return names
+		}
+	}
+	return append(names, "SHA256SUMS")
+}
+
+// provenanceDests are the keys of the provenance statements and their
+// signatures
+func (u *uploader) provenanceDests() []string {
+	dests := []string{}
+	if !u.Opts.Provenance {
+		return dests
+	}
+
+	for _, targetPath := range u.Opts.TargetPaths {
+		dest := publishedDest(targetPath, provenanceName)
+		dests = append(dests, dest)
+		if u.Opts.SigningKey != "" {
+			dests = append(dests, dest+signing.Ext)
+		}
+	}
+	return dests
+}
+
+// provenance makes the statement for the target path's artifacts
+func (u *uploader) provenance(targetPath string) ([]byte, error) {
+	dests, sums, err := u.checksums.Sums(targetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &inTotoStatement{
+		Type:          inTotoStatementType,
+		Subject:       []*inTotoSubject{},
+		PredicateType: slsaPredicateType,
+		Predicate:     &provenancePredicate{},
+	}
+
+	for _, dest := range dests {
+		subject := &inTotoSubject{Name: dest, Digest: map[string]string{}}
+		for name, sum := range sums[dest] {
+			subject.Digest[provenanceDigests[name]] = sum
+		}
+		statement.Subject = append(statement.Subject, subject)
+	}
+
+	p := statement.Predicate
+	p.BuildDefinition.BuildType = provenanceBuildType
+	p.BuildDefinition.ExternalParameters = map[string]string{
+		"repository":   u.Opts.RepoSlug,
+		"branch":       u.Opts.Branch,
+		"tag":          u.Opts.Tag,
+		"pull_request": u.Opts.PullRequest,
+		"commit":       u.Opts.Commit,
+		"target_path":  targetPath,
+	}
+	p.BuildDefinition.InternalParameters = map[string]string{
+		"ci":           u.Opts.detectedCI,
+		"build_number": u.Opts.BuildNumber,
+		"build_id":     u.Opts.BuildID,
+		"job_number":   u.Opts.JobNumber,
+		"job_id":       u.Opts.JobID,
+		"os":           u.Opts.OS,
+	}
+
+	p.RunDetails.Builder.ID = u.Opts.provenanceBuilder()
+	p.RunDetails.Metadata.InvocationID = u.Opts.JobID
+	p.RunDetails.Metadata.StartedOn = u.startTime.UTC().Format(time.RFC3339)
+	p.RunDetails.Metadata.FinishedOn = time.Now().UTC().Format(time.RFC3339)
+
+	return json.MarshalIndent(statement, "", "  ")
+}
+
+func (opts *Options) provenanceBuilder() string {
+	if opts.ProvenanceBuilder != "" {
+		return opts.ProvenanceBuilder
+	}
+
+	if opts.detectedCI != "" {
+		return provenanceBuilderCI + opts.detectedCI
+	}
+	return provenanceBuilderCI + "local"
+}
+
+// publishProvenance uploads a provenance statement to each target path,
+// signed if there is a signing key
+func (u *uploader) publishProvenance() error {
+	if !u.Opts.Provenance {
+		return nil
+	}
+
+	if u.incomplete {
+		u.log.Warn("not publishing provenance after an incomplete walk")
+		return nil
+	}
+
+	for _, targetPath := range u.Opts.TargetPaths {
+		statement, err := u.provenance(targetPath)
+		if err != nil {
+			return err
+		}
+
+		err = u.publishSigned(targetPath, provenanceName, statement, provenanceContentType)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/travis-ci/artifacts/signing"
+)
+
+func TestUploaderUploadProvenance(t *testing.T) {
+	secret, _ := testSigningKeys(t)
+
+	opts := NewOptions()
+	opts.Paths = []string{testArtifactPathDir}
+	opts.TargetPaths = []string{"artifacts/1"}
+	opts.Provenance = true
+	opts.SigningKey = secret
+	opts.RepoSlug = "owner/repo"
+	opts.Commit = "abc123"
+	opts.ProvenanceBuilder = "https://example.com/builder"
+
+	rp := &recordingProvider{name: "ok"}
+	u := newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{rp}
+
+	err := u.Upload()
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	dests := map[string]bool{}
+	for _, a := range rp.uploaded {
+		dests[a.FullDest()] = true
+	}
+
+	for _, dest := range []string{
+		"artifacts/1/" + provenanceName,
+		"artifacts/1/" + provenanceName + signing.Ext,
+	} {
+		if !dests[dest] {
+			t.Fatalf("%v not uploaded: %v", dest, dests)
+		}
+	}
+
+	b, err := u.provenance("artifacts/1")
+	if err != nil {
+		t.Fatalf("failed to make provenance: %v", err)
+	}
+
+	statement := &inTotoStatement{}
+	err = json.Unmarshal(b, statement)
+	if err != nil {
+		t.Fatalf("invalid statement %s: %v", b, err)
+	}
+
+	if statement.Type != inTotoStatementType || statement.PredicateType != slsaPredicateType {
+		t.Fatalf("unexpected statement types %v, %v", statement.Type, statement.PredicateType)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(testArtifactPathDir, "foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+
+	found := false
+	for _, subject := range statement.Subject {
+		if subject.Name == provenanceName || subject.Name == provenanceName+signing.Ext {
+			t.Fatalf("provenance lists itself")
+		}
+		if subject.Name == "foo" {
+			found = subject.Digest["sha256"] == hex.EncodeToString(sum[:])
+		}
+	}
+
+	if !found {
+		t.Fatalf("foo not a subject with its digest: %s", b)
+	}
+
+	p := statement.Predicate
+	if p.RunDetails.Builder.ID != "https://example.com/builder" {
+		t.Fatalf("%v != https://example.com/builder", p.RunDetails.Builder.ID)
+	}
+
+	if p.BuildDefinition.ExternalParameters["repository"] != "owner/repo" ||
+		p.BuildDefinition.ExternalParameters["commit"] != "abc123" {
+		t.Fatalf("unexpected external parameters %v", p.BuildDefinition.ExternalParameters)
+	}
+}
+
+func TestProvenanceDests(t *testing.T) {
+	opts := NewOptions()
+	opts.TargetPaths = []string{"artifacts/1/", "/"}
+	opts.Checksums = []string{"SHA256SUMS"}
+	opts.Provenance = true
+
+	u := newUploader(opts, getPanicLogger())
+
+	dests := append(u.checksumDests(), u.provenanceDests()...)
+	expected := []string{
+		"artifacts/1/SHA256SUMS", "SHA256SUMS",
+		"artifacts/1/" + provenanceName, provenanceName,
+	}
+
+	if len(dests) != len(expected) {
+		t.Fatalf("%v != %v", dests, expected)
+	}
+
+	for i := range expected {
+		if dests[i] != expected[i] {
+			t.Fatalf("%v != %v", dests[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package upload
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/cas"
+	"github.com/travis-ci/artifacts/remote"
+)
+
+// casUpload stores the artifact once as a blob keyed by its SHA-256,
+// skipping the upload if the blob already exists, and puts an empty
+// pointer object at the artifact's destination
+func (s3p *s3Provider) casUpload(opts *Options, b *s3.Bucket, a *artifact.Artifact,
+	reader io.Reader, size uint64, ctype string, headers map[string][]string) error {
+
+	sum, err := cas.FileSHA256(a.Source)
+	if err != nil {
+		return err
+	}
+
+	blobKey := cas.BlobKey(opts.CASPrefix, sum)
+	logFields := logrus.Fields{
+		"source": a.Source,
+		"dest":   a.FullDest(),
+		"blob":   blobKey,
+	}
+
+	blobHeaders, err := remote.Head(b, blobKey)
+	if err != nil && !remote.IsNotFound(err) {
+		return err
+	}
+
+	if err == nil {
+		s3p.log.WithFields(logFields).Debug("blob already stored, skipping upload")
+		err = s3p.fixBlob(opts, b, blobKey, a, size, blobHeaders)
+		if err != nil {
+			return err
+		}
+	} else {
+		s3p.log.WithFields(logFields).Debug("storing blob")
+		err = s3p.put(opts, b, blobKey, a, reader, size, headers)
+		if err != nil {
+			return err
+		}
+	}
+
+	pointerHeaders := cas.PointerHeaders(blobKey, sum)
+	pointerHeaders["Content-Type"] = []string{ctype}
+	pointerHeaders["Cache-Control"] = []string{a.CacheControl}
+
+	return b.PutReaderHeader(a.FullDest(), bytes.NewReader([]byte{}), 0, pointerHeaders, a.Perm)
+}
+
+// fixBlob copies a stored blob onto itself when its permissions or
+// cache control differ from the artifact's, as it otherwise keeps those
+// of whichever build stored it first
+func (s3p *s3Provider) fixBlob(opts *Options, b *s3.Bucket, key string,
+	a *artifact.Artifact, size uint64, headers http.Header) error {
+
+	perm, err := remote.ACL(b, key)
+	if err != nil {
+		s3p.log.WithFields(logrus.Fields{
+			"blob": key,
+			"err":  err,
+		}).Warn("failed to get blob permissions, leaving them as they are")
+		return nil
+	}
+
+	// grants only read back as one of these canned ACLs
+	wanted := a.Perm
+	switch wanted {
+	case s3.PublicRead, s3.PublicReadWrite, s3.AuthenticatedRead:
+	default:
+		wanted = s3.Private
+	}
+
+	if perm == wanted && headers.Get("Cache-Control") == a.CacheControl {
+		return nil
+	}
+
+	s3p.log.WithFields(logrus.Fields{
+		"blob":          key,
+		"permissions":   a.Perm,
+		"cache_control": a.CacheControl,
+	}).Debug("updating blob settings")
+
+	headers.Set("Cache-Control", a.CacheControl)
+	return remote.Copy(b, key, key, int64(size), int64(opts.PartSize), headers, a.Perm)
+}
@@ -0,0 +1,84 @@
+package upload
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/cas"
+)
+
+func TestS3ProviderCASFixesBlob(t *testing.T) {
+	opts := NewOptions()
+	opts.CAS = true
+	opts.CASPrefix = "cas-test"
+
+	sum, err := cas.FileSHA256(testArtifactPaths[0].Path)
+	if err != nil {
+		t.Fatalf("failed to hash artifact: %v", err)
+	}
+	blobKey := cas.BlobKey(opts.CASPrefix, sum)
+
+	var mu sync.Mutex
+	copies := []http.Header{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		isBlob := strings.HasSuffix(r.URL.Path, "/"+blobKey)
+
+		switch {
+		case r.Method == "HEAD" && isBlob:
+			w.Header().Set("Cache-Control", "max-age=1")
+		case r.Method == "HEAD":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == "GET" && r.URL.Query()["acl"] != nil:
+			w.Write([]byte(`<AccessControlPolicy><AccessControlList></AccessControlList></AccessControlPolicy>`))
+		case r.Method == "PUT" && r.Header.Get("X-Amz-Copy-Source") != "":
+			mu.Lock()
+			copies = append(copies, r.Header)
+			mu.Unlock()
+			w.Write([]byte(`<CopyObjectResult><ETag>"whatever"</ETag></CopyObjectResult>`))
+		case r.Method == "PUT":
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	defer ts.Close()
+
+	s3p := newS3Provider(opts, getPanicLogger())
+	b := s3.New(aws.Auth{AccessKey: "whatever", SecretKey: "whatever"},
+		aws.Region{Name: "faux-region-9000", S3Endpoint: ts.URL}).Bucket("bucket")
+
+	a := artifact.New("artifacts", testArtifactPaths[0].Path, "1/foo", &artifact.Options{
+		Perm:         s3.PublicRead,
+		CacheControl: "max-age=60",
+	})
+
+	err = s3p.rawUpload(opts, b, a, nil)
+	if err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(copies) != 1 {
+		t.Fatalf("blob copies %v != 1", len(copies))
+	}
+
+	if copies[0].Get("X-Amz-Copy-Source") != "/bucket/"+blobKey {
+		t.Fatalf("copy source %v != /bucket/%v", copies[0].Get("X-Amz-Copy-Source"), blobKey)
+	}
+
+	if copies[0].Get("X-Amz-Acl") != "public-read" {
+		t.Fatalf("acl %v != public-read", copies[0].Get("X-Amz-Acl"))
+	}
+
+	if copies[0].Get("Cache-Control") != "max-age=60" {
+		t.Fatalf("cache control %v != max-age=60", copies[0].Get("Cache-Control"))
+	}
+}
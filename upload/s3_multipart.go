@@ -0,0 +1,119 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/journal"
+	"github.com/travis-ci/artifacts/remote"
+)
+
+const (
+	minPartSize = uint64(1024 * 1024 * 5)
+)
+
+// multipartUpload uploads the artifact in parts.  If resumable, the
+// upload is recorded in the journal so that an interrupted upload can
+// be resumed by a later attempt or run.  Parts already uploaded are
+// only skipped if their size and MD5 match.
+func (s3p *s3Provider) multipartUpload(b *s3.Bucket, dest string, headers map[string][]string,
+	reader io.Reader, a *artifact.Artifact, partSize uint64, resumable bool) error {
+
+	var multi *s3.Multi
+	uploaded := map[int]s3.Part{}
+
+	var m *journal.Multipart
+	if resumable {
+		m = s3p.journal.Multipart(s3p.Name(), dest, a.Source)
+	}
+	if m != nil && uint64(m.PartSize) == partSize {
+		multi = &s3.Multi{Bucket: b, Key: dest, UploadId: m.UploadID}
+
+		parts, err := multi.ListParts()
+		if err != nil {
+			s3p.log.WithFields(logrus.Fields{
+				"dest":      dest,
+				"upload_id": m.UploadID,
+				"err":       err,
+			}).Warn("failed to list parts, starting multipart upload over")
+			multi = nil
+		} else {
+			for _, p := range parts {
+				uploaded[p.N] = p
+			}
+			s3p.log.WithFields(logrus.Fields{
+				"dest":      dest,
+				"upload_id": m.UploadID,
+				"parts":     len(parts),
+			}).Info("resuming multipart upload")
+		}
+	}
+
+	if multi == nil {
+		var err error
+		initHeaders := http.Header{"X-Amz-Acl": {string(a.Perm)}}
+		for name, values := range headers {
+			initHeaders[name] = values
+		}
+
+		multi, err = remote.InitMulti(b, dest, initHeaders)
+		if err != nil {
+			return err
+		}
+
+		if resumable {
+			err = s3p.journal.StartMultipart(s3p.Name(), dest, a.Source, multi.UploadId, int64(partSize))
+			if err != nil {
+				s3p.log.WithFields(logrus.Fields{
+					"dest": dest,
+					"err":  err,
+				}).Warn("failed to record multipart upload in journal")
+			}
+		}
+	}
+
+	parts := []s3.Part{}
+	buf := make([]byte, partSize)
+
+	for n := 1; ; n++ {
+		size, err := io.ReadFull(reader, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		if p, ok := uploaded[n]; ok && p.Size == int64(size) && partETagMatches(p, buf[:size]) {
+			s3p.log.WithFields(logrus.Fields{
+				"dest": dest,
+				"part": n,
+			}).Debug("skipping uploaded part")
+			parts = append(parts, p)
+		} else {
+			p, err := multi.PutPart(n, bytes.NewReader(buf[:size]))
+			if err != nil {
+				return err
+			}
+			parts = append(parts, p)
+		}
+
+		if size < len(buf) {
+			break
+		}
+	}
+
+	return multi.Complete(parts)
+}
+
+func partETagMatches(p s3.Part, b []byte) bool {
+	sum := md5.Sum(b)
+	return strings.Trim(p.ETag, `"`) == hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,196 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/encryption"
+	"github.com/travis-ci/artifacts/journal"
+)
+
+func TestS3ProviderMultipartUploadHeaders(t *testing.T) {
+	var mu sync.Mutex
+	initHeaders := http.Header{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		q := r.URL.Query()
+
+		switch {
+		case r.Method == "POST" && q["uploads"] != nil:
+			mu.Lock()
+			initHeaders = r.Header
+			mu.Unlock()
+			w.Write([]byte(`<InitiateMultipartUploadResult><UploadId>up-1</UploadId></InitiateMultipartUploadResult>`))
+		case r.Method == "PUT" && q.Get("partNumber") != "":
+			sum := md5.Sum(b)
+			w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		case r.Method == "POST" && q.Get("uploadId") != "":
+			w.Write([]byte(`<CompleteMultipartUploadResult><ETag>"whatever"</ETag></CompleteMultipartUploadResult>`))
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	defer ts.Close()
+
+	source := filepath.Join(testTmp, "multipart-headers.txt")
+	err := ioutil.WriteFile(source, []byte("0123456789"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	defer os.Remove(source)
+
+	journalFile := filepath.Join(testTmp, "multipart-headers.json")
+	defer os.Remove(journalFile)
+	j, err := journal.Open(journalFile)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+
+	opts := NewOptions()
+	opts.PartSize = 4
+
+	s3p := newS3Provider(opts, getPanicLogger())
+	s3p.journal = j
+
+	b := s3.New(aws.Auth{AccessKey: "whatever", SecretKey: "whatever"},
+		aws.Region{Name: "faux-region-9000", S3Endpoint: ts.URL}).Bucket("bucket")
+
+	a := artifact.New("bucket", source, "docs/link.txt", &artifact.Options{
+		Perm:         s3.PublicRead,
+		CacheControl: "max-age=60",
+		Rules: artifact.Rules{
+			&artifact.Rule{ContentDisposition: "attachment"},
+		},
+	})
+
+	err = s3p.rawUpload(opts, b, a, nil)
+	if err != nil {
+		t.Fatalf("multipart upload failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for name, value := range map[string]string{
+		"Cache-Control":       "max-age=60",
+		"Content-Disposition": `attachment; filename="link.txt"`,
+		"Content-Type":        "text/plain; charset=utf-8",
+		"X-Amz-Acl":           "public-read",
+	} {
+		if initHeaders.Get(name) != value {
+			t.Fatalf("%s %q != %q", name, initHeaders.Get(name), value)
+		}
+	}
+}
+
+func TestS3ProviderEncryptedMultipartUpload(t *testing.T) {
+	var mu sync.Mutex
+	initHeaders := http.Header{}
+	parts := map[string][]byte{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		q := r.URL.Query()
+
+		switch {
+		case r.Method == "POST" && q["uploads"] != nil:
+			mu.Lock()
+			initHeaders = r.Header
+			mu.Unlock()
+			w.Write([]byte(`<InitiateMultipartUploadResult><UploadId>up-1</UploadId></InitiateMultipartUploadResult>`))
+		case r.Method == "PUT" && q.Get("partNumber") != "":
+			mu.Lock()
+			parts[q.Get("partNumber")] = b
+			mu.Unlock()
+			sum := md5.Sum(b)
+			w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		case r.Method == "POST" && q.Get("uploadId") != "":
+			w.Write([]byte(`<CompleteMultipartUploadResult><ETag>"whatever"</ETag></CompleteMultipartUploadResult>`))
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	defer ts.Close()
+
+	source := filepath.Join(testTmp, "multipart-encrypted.txt")
+	err := ioutil.WriteFile(source, []byte("0123456789"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	defer os.Remove(source)
+
+	journalFile := filepath.Join(testTmp, "multipart-encrypted.json")
+	defer os.Remove(journalFile)
+	j, err := journal.Open(journalFile)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+
+	opts := NewOptions()
+	opts.EncryptionPassphrase = "hunter2"
+	opts.PartSize = 16
+
+	key, err := encryption.NewKey(opts.EncryptionPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s3p := newS3Provider(opts, getPanicLogger())
+	s3p.encryption = key
+	s3p.journal = j
+
+	b := s3.New(aws.Auth{AccessKey: "whatever", SecretKey: "whatever"},
+		aws.Region{Name: "faux-region-9000", S3Endpoint: ts.URL}).Bucket("bucket")
+
+	a := artifact.New("bucket", source, "dumps/secret.txt", &artifact.Options{
+		Perm: s3.Private,
+	})
+
+	err = s3p.rawUpload(opts, b, a, nil)
+	if err != nil {
+		t.Fatalf("encrypted multipart upload failed: %v", err)
+	}
+
+	if j.Multipart(s3p.Name(), a.FullDest(), source) != nil {
+		t.Fatalf("encrypted multipart upload was recorded as resumable")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(parts) < 2 {
+		t.Fatalf("parts %v < 2", len(parts))
+	}
+
+	var encrypted []byte
+	for n := 1; n <= len(parts); n++ {
+		encrypted = append(encrypted, parts[strconv.Itoa(n)]...)
+	}
+
+	r, err := encryption.NewDecrypter(opts.EncryptionPassphrase).Decrypt(bytes.NewReader(encrypted), initHeaders)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+
+	decrypted, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+
+	if string(decrypted) != "0123456789" {
+		t.Fatalf("%q != %q", string(decrypted), "0123456789")
+	}
+}
@@ -2,6 +2,7 @@ package upload
 
 import (
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -9,6 +10,10 @@ import (
 	"github.com/mitchellh/goamz/aws"
 	"github.com/mitchellh/goamz/s3"
 	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/encryption"
+	"github.com/travis-ci/artifacts/journal"
+	"github.com/travis-ci/artifacts/logging"
+	"github.com/travis-ci/artifacts/tracing"
 )
 
 var (
@@ -21,8 +26,15 @@ type s3Provider struct {
 	opts *Options
 	log  *logrus.Logger
 
+	// encryption encrypts artifacts other than preserved symlinks, if
+	// a passphrase was given
+	encryption *encryption.Key
+
 	overrideConn *s3.S3
 	overrideAuth aws.Auth
+
+	tracer  *tracing.Tracer
+	journal *journal.Journal
 }
 
 func newS3Provider(opts *Options, log *logrus.Logger) *s3Provider {
@@ -60,13 +72,17 @@ func (s3p *s3Provider) Upload(id string, opts *Options, in chan *artifact.Artifa
 	}
 
 	for a := range in {
-		err := s3p.uploadFile(opts, bucket, a)
+		span := startArtifactSpan(s3p.tracer, s3p.Name(), a)
+		start := time.Now()
+		err := s3p.uploadFile(opts, bucket, a, span)
+		a.UploadResult.Duration = time.Since(start)
 		if err != nil {
 			a.UploadResult.OK = false
 			a.UploadResult.Err = err
 		} else {
 			a.UploadResult.OK = true
 		}
+		endArtifactSpan(span, a)
 		out <- a
 	}
 
@@ -74,16 +90,21 @@ func (s3p *s3Provider) Upload(id string, opts *Options, in chan *artifact.Artifa
 	return
 }
 
-func (s3p *s3Provider) uploadFile(opts *Options, b *s3.Bucket, a *artifact.Artifact) error {
+func (s3p *s3Provider) uploadFile(opts *Options, b *s3.Bucket, a *artifact.Artifact, span *tracing.Span) error {
 	retries := uint64(0)
 
 	for {
-		err := s3p.rawUpload(opts, b, a)
+		attempt := s3p.tracer.Start("artifact.upload.attempt", span)
+		attempt.SetAttribute("attempt", retries+1)
+		err := s3p.rawUpload(opts, b, a, attempt)
+		attempt.SetError(err)
+		attempt.End()
 		if err == nil {
 			return nil
 		}
-		if retries < opts.Retries {
+		if retries < opts.Retries && !a.Aborted() {
 			retries++
+			a.UploadResult.Retries = retries
 			s3p.log.WithFields(logrus.Fields{
 				"artifact": a.Source,
 				"retry":    retries,
@@ -98,14 +119,14 @@ func (s3p *s3Provider) uploadFile(opts *Options, b *s3.Bucket, a *artifact.Artif
 	return nil
 }
 
-func (s3p *s3Provider) rawUpload(opts *Options, b *s3.Bucket, a *artifact.Artifact) error {
+func (s3p *s3Provider) rawUpload(opts *Options, b *s3.Bucket, a *artifact.Artifact, span *tracing.Span) error {
 	dest := a.FullDest()
 	reader, err := a.Reader()
 	if err != nil {
 		return err
 	}
 
-	ctype := a.ContentType()
+	ctype := tracedContentType(s3p.tracer, span, a)
 	size, err := a.Size()
 	if err != nil {
 		return err
@@ -122,19 +143,46 @@ func (s3p *s3Provider) rawUpload(opts *Options, b *s3.Bucket, a *artifact.Artifa
 		"dest":             dest,
 		"bucket":           b.Name,
 		"content_type":     ctype,
-		"cache_control":    opts.CacheControl,
+		"cache_control":    a.CacheControl,
+		"disposition":      a.ContentDisposition,
+		"permissions":      a.Perm,
 	}).Debug("more artifact details")
 
-	err = b.PutReaderHeader(dest, reader, int64(size),
-		map[string][]string{
-			"Content-Type":  []string{ctype},
-			"Cache-Control": []string{opts.CacheControl},
-		}, a.Perm)
-	if err != nil {
-		return err
+	headers := map[string][]string{
+		"Content-Type":  []string{ctype},
+		"Cache-Control": []string{a.CacheControl},
 	}
 
-	return nil
+	if a.ContentDisposition != "" {
+		headers["Content-Disposition"] = []string{a.ContentDisposition}
+	}
+
+	if a.SymlinkTarget != "" {
+		headers["x-amz-meta-symlink-target"] = []string{a.SymlinkTarget}
+		if location := a.RedirectLocation(); location != "" {
+			headers["x-amz-website-redirect-location"] = []string{location}
+		}
+	}
+
+	if s3p.encryption != nil && a.SymlinkTarget == "" {
+		return s3p.encryptedUpload(opts, b, dest, a, reader, size, headers)
+	}
+
+	if opts.CAS && a.SymlinkTarget == "" {
+		return s3p.casUpload(opts, b, a, reader, size, ctype, headers)
+	}
+
+	return s3p.put(opts, b, dest, a, reader, size, headers)
+}
+
+func (s3p *s3Provider) put(opts *Options, b *s3.Bucket, key string, a *artifact.Artifact,
+	reader io.Reader, size uint64, headers map[string][]string) error {
+
+	if s3p.journal != nil && size > opts.PartSize {
+		return s3p.multipartUpload(b, key, headers, reader, a, opts.PartSize, true)
+	}
+
+	return b.PutReaderHeader(key, reader, int64(size), headers, a.Perm)
 }
 
 func (s3p *s3Provider) getConn(auth aws.Auth) *s3.S3 {
@@ -148,12 +196,20 @@ func (s3p *s3Provider) getConn(auth aws.Auth) *s3.S3 {
 
 func (s3p *s3Provider) getAuth(accessKey, secretKey string) (aws.Auth, error) {
 	if s3p.overrideAuth != nilAuth {
+		logging.AddSecrets(s3p.log, s3p.overrideAuth.AccessKey, s3p.overrideAuth.SecretKey, s3p.overrideAuth.Token)
 		s3p.log.WithField("auth", s3p.overrideAuth).Debug("using override auth")
 		return s3p.overrideAuth, nil
 	}
 
 	s3p.log.Debug("creating new auth")
-	return aws.GetAuth(accessKey, secretKey)
+	auth, err := aws.GetAuth(accessKey, secretKey)
+	if err != nil {
+		return auth, err
+	}
+
+	// credentials from an instance role never went through the options
+	logging.AddSecrets(s3p.log, auth.AccessKey, auth.SecretKey, auth.Token)
+	return auth, nil
 }
 
 func (s3p *s3Provider) getRegion() aws.Region {
@@ -2,6 +2,7 @@ package upload
 
 import (
 	"fmt"
+	"io/ioutil"
 	"testing"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/mitchellh/goamz/s3"
 	"github.com/mitchellh/goamz/s3/s3test"
 	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/encryption"
 )
 
 var (
@@ -131,3 +133,94 @@ func TestS3ProviderRegionOption(t *testing.T) {
 		}
 	}
 }
+
+func TestS3ProviderCASUpload(t *testing.T) {
+	opts := NewOptions()
+	opts.CAS = true
+	opts.CASPrefix = "cas-test"
+
+	s3p := newS3Provider(opts, getPanicLogger())
+	b := testS3.Bucket("bucket")
+
+	for _, dest := range []string{"1/foo", "2/foo"} {
+		a := artifact.New("artifacts", testArtifactPaths[0].Path, dest, &artifact.Options{
+			Perm: s3.PublicRead,
+		})
+
+		err := s3p.rawUpload(opts, b, a, nil)
+		if err != nil {
+			t.Fatalf("failed to upload %v: %v", dest, err)
+		}
+	}
+
+	resp, err := b.List("cas-test/", "", "", 10)
+	if err != nil {
+		t.Fatalf("failed to list blobs: %v", err)
+	}
+
+	if len(resp.Contents) != 1 {
+		t.Fatalf("blobs length %v != 1", len(resp.Contents))
+	}
+
+	pointer, err := b.GetResponse("artifacts/2/foo")
+	if err != nil {
+		t.Fatalf("failed to get pointer: %v", err)
+	}
+	defer pointer.Body.Close()
+
+	if pointer.Header.Get("x-amz-meta-cas-blob") != resp.Contents[0].Key {
+		t.Fatalf("pointer %v != %v", pointer.Header.Get("x-amz-meta-cas-blob"), resp.Contents[0].Key)
+	}
+}
+
+func TestS3ProviderEncryptedUpload(t *testing.T) {
+	opts := NewOptions()
+	opts.EncryptionPassphrase = "hunter2"
+
+	key, err := encryption.NewKey(opts.EncryptionPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s3p := newS3Provider(opts, getPanicLogger())
+	s3p.encryption = key
+	b := testS3.Bucket("bucket")
+
+	a := artifact.New("artifacts", testArtifactPaths[0].Path, "encrypted/foo", &artifact.Options{
+		Perm: s3.PublicRead,
+	})
+
+	err = s3p.rawUpload(opts, b, a, nil)
+	if err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+
+	resp, err := b.GetResponse("artifacts/encrypted/foo")
+	if err != nil {
+		t.Fatalf("failed to get encrypted artifact: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if !encryption.IsEncrypted(resp.Header) {
+		t.Fatalf("artifact not stored with encryption metadata: %v", resp.Header)
+	}
+
+	r, err := encryption.NewDecrypter(opts.EncryptionPassphrase).Decrypt(resp.Body, resp.Header)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+
+	decrypted, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+
+	expected, err := ioutil.ReadFile(testArtifactPaths[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(decrypted) != string(expected) {
+		t.Fatalf("%q != %q", string(decrypted), string(expected))
+	}
+}
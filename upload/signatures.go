@@ -0,0 +1,94 @@
+package upload
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/travis-ci/artifacts/signing"
+)
+
+const (
+	plainTextContentType = "text/plain; charset=utf-8"
+)
+
+// signatureSet writes a detached signature for each artifact to a
+// temporary directory, from where it is uploaded next to the artifact.
+// A nil *signatureSet signs nothing.
+type signatureSet struct {
+	signer *signing.Signer
+	dir    string
+	count  int
+}
+
+func newSignatureSet(key string) (*signatureSet, error) {
+	signer, err := signing.NewSigner(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "artifacts-signatures")
+	if err != nil {
+		return nil, err
+	}
+
+	return &signatureSet{signer: signer, dir: dir}, nil
+}
+
+// Sign writes the signature of source, returning its filename and size,
+// or "" for symlinks uploaded as such, which have no content to sign.
+// It is only called from the feeder, so needs no lock.
+func (ss *signatureSet) Sign(source, dest, linkTarget string) (string, uint64, error) {
+	if ss == nil || linkTarget != "" {
+		return "", 0, nil
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	sig, err := ss.signature(f, dest)
+	if err != nil {
+		return "", 0, err
+	}
+
+	ss.count++
+	filename := filepath.Join(ss.dir, fmt.Sprintf("%d%s", ss.count, signing.Ext))
+	err = ioutil.WriteFile(filename, sig, 0600)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return filename, uint64(len(sig)), nil
+}
+
+// signature signs the message, trusting the base name of where it goes.
+// The trusted comment carries no timestamp, so that an unchanged
+// artifact gets an unchanged signature.
+func (ss *signatureSet) signature(message io.Reader, dest string) ([]byte, error) {
+	return ss.signer.Sign(message, "file:"+filepath.Base(dest))
+}
+
+// Remove deletes the signatures once they are uploaded
+func (ss *signatureSet) Remove() error {
+	if ss == nil {
+		return nil
+	}
+	return os.RemoveAll(ss.dir)
+}
+
+func (opts *Options) validateSigning() error {
+	if opts.SigningKey == "" {
+		return nil
+	}
+
+	if _, err := signing.NewSigner(opts.SigningKey); err != nil {
+		return fmt.Errorf("invalid signing key: %v", err)
+	}
+
+	return nil
+}
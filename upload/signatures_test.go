@@ -0,0 +1,118 @@
+package upload
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/travis-ci/artifacts/signing"
+)
+
+// testSigningKeys makes an unencrypted minisign key pair
+func testSigningKeys(t *testing.T) (string, string) {
+	pub, sk, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyID := []byte("artifact")
+	secret := append([]byte("Ed\x00\x00B2"), make([]byte, 32+8+8)...)
+	secret = append(append(append(secret, keyID...), sk...), make([]byte, 32)...)
+	public := append(append([]byte("Ed"), keyID...), pub...)
+
+	return base64.StdEncoding.EncodeToString(secret), base64.StdEncoding.EncodeToString(public)
+}
+
+func TestSignatureSetSign(t *testing.T) {
+	secret, public := testSigningKeys(t)
+
+	ss, err := newSignatureSet(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Remove()
+
+	source := testArtifactPaths[0].Path
+	filename, size, err := ss.Sign(source, "foo/bar.txt", "")
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	signature, err := ioutil.ReadFile(filename)
+	if err != nil || uint64(len(signature)) != size {
+		t.Fatalf("%v != %v (err %v)", len(signature), size, err)
+	}
+
+	message, err := os.Open(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer message.Close()
+
+	verifier, err := signing.NewVerifier(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comment, err := verifier.Verify(message, signature)
+	if err != nil {
+		t.Fatalf("signature did not verify: %v", err)
+	}
+
+	if comment != "file:bar.txt" {
+		t.Fatalf("%v != file:bar.txt", comment)
+	}
+
+	if filename, _, _ := ss.Sign(source, "link", "target"); filename != "" {
+		t.Fatalf("symlink was signed")
+	}
+
+	ss.Remove()
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatalf("signature not removed: %v", err)
+	}
+}
+
+func TestUploaderUploadSignatures(t *testing.T) {
+	secret, _ := testSigningKeys(t)
+
+	opts := NewOptions()
+	opts.Paths = []string{testArtifactPathDir}
+	opts.TargetPaths = []string{"artifacts/1"}
+	opts.SigningKey = secret
+
+	rp := &recordingProvider{name: "ok"}
+	u := newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{rp}
+
+	err := u.Upload()
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	dests := map[string]bool{}
+	for _, a := range rp.uploaded {
+		dests[a.FullDest()] = true
+	}
+
+	for dest := range dests {
+		if strings.HasSuffix(dest, signing.Ext) {
+			continue
+		}
+		if !dests[dest+signing.Ext] {
+			t.Fatalf("no signature uploaded for %v", dest)
+		}
+	}
+
+	if len(dests) == 0 || len(dests)%2 != 0 {
+		t.Fatalf("unexpected uploads %v", dests)
+	}
+
+	opts.SigningKey = "does-not-exist.key"
+	if opts.validateSigning() == nil {
+		t.Fatalf("invalid signing key was valid")
+	}
+}
@@ -0,0 +1,37 @@
+package upload
+
+import (
+	"fmt"
+
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/summary"
+)
+
+const (
+	// FailurePolicyIgnore never fails the run because of failed artifacts
+	FailurePolicyIgnore = summary.FailurePolicyIgnore
+	// FailurePolicyAny fails the run if any artifact failed on any
+	// destination
+	FailurePolicyAny = summary.FailurePolicyAny
+	// FailurePolicyAll fails the run if any artifact failed on every
+	// destination
+	FailurePolicyAll = summary.FailurePolicyAll
+)
+
+var (
+	failurePolicies = summary.FailurePolicies
+)
+
+// uploadSummary tallies results per destination provider
+type uploadSummary struct {
+	*summary.Summary
+}
+
+func newUploadSummary(providers []string) *uploadSummary {
+	return &uploadSummary{Summary: summary.New("upload", "uploaded", providers)}
+}
+
+func (us *uploadSummary) Record(a *artifact.Artifact) {
+	us.Summary.Record(a.UploadResult.Provider, fmt.Sprintf("%s -> %s", a.Source, a.FullDest()),
+		a.UploadResult.OK, a.UploadResult.Retries)
+}
@@ -0,0 +1,135 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dustin/go-humanize"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/travis-ci/artifacts/artifact"
+)
+
+const (
+	// GitHub drops a step summary over 1MiB, so each table is cut well
+	// short of that
+	markdownTableMaxRows  = 500
+	markdownTableMaxBytes = 256 * 1024
+)
+
+var (
+	markdownCellEscaper = strings.NewReplacer("|", "\\|", "[", "\\[", "]", "\\]", "`", "\\`",
+		"\n", " ", "\r", "")
+)
+
+// writeSummaryMarkdown appends a Markdown report of the artifacts to
+// the summary file, which on GitHub Actions is shown on the job page
+func (u *uploader) writeSummaryMarkdown(completed, failed []*artifact.Artifact) {
+	if u.Opts.SummaryMarkdown == "" {
+		return
+	}
+
+	f, err := os.OpenFile(u.Opts.SummaryMarkdown, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err == nil {
+		_, err = f.Write(u.summaryMarkdown(completed, failed))
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		u.log.WithFields(logrus.Fields{
+			"file": u.Opts.SummaryMarkdown,
+			"err":  err,
+		}).Warn("failed to write markdown summary")
+	}
+}
+
+func (u *uploader) summaryMarkdown(completed, failed []*artifact.Artifact) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "### Artifacts\n\n%d uploaded, %d failed\n\n", len(completed), len(failed))
+
+	if len(completed) > 0 {
+		buf.WriteString("| Artifact | Provider | Size | Content type |\n")
+		buf.WriteString("| --- | --- | ---: | --- |\n")
+		writeMarkdownRows(&buf, completed, func(a *artifact.Artifact) []string {
+			size, _ := a.Size()
+			return []string{u.markdownLink(a), markdownCell(a.UploadResult.Provider),
+				humanize.Bytes(size), markdownCell(a.ContentType())}
+		})
+		buf.WriteString("\n")
+	}
+
+	if len(failed) > 0 {
+		buf.WriteString("#### Failed\n\n")
+		buf.WriteString("| Artifact | Provider | Source | Error |\n")
+		buf.WriteString("| --- | --- | --- | --- |\n")
+		writeMarkdownRows(&buf, failed, func(a *artifact.Artifact) []string {
+			return []string{markdownCell(a.FullDest()), markdownCell(a.UploadResult.Provider),
+				markdownCell(a.Source), markdownCell(fmt.Sprintf("%v", a.UploadResult.Err))}
+		})
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes()
+}
+
+// markdownLink links to s3 artifacts, the only ones with a known URL
+func (u *uploader) markdownLink(a *artifact.Artifact) string {
+	dest := markdownCell(a.FullDest())
+	if a.UploadResult.Provider != "s3" {
+		return dest
+	}
+
+	region, ok := aws.Regions[u.Opts.S3Region]
+	if !ok || region.S3Endpoint == "" {
+		return dest
+	}
+
+	link := strings.TrimRight(region.S3Endpoint, "/") + "/" + u.Opts.BucketName + "/" +
+		(&url.URL{Path: a.FullDest()}).EscapedPath()
+	return fmt.Sprintf("[%s](%s)", dest, link)
+}
+
+// writeMarkdownRows writes a table row of cells for each artifact, in
+// order, cutting the table short with a row saying how many were left
+func writeMarkdownRows(buf *bytes.Buffer, artifacts []*artifact.Artifact, cells func(*artifact.Artifact) []string) {
+	start := buf.Len()
+	for i, a := range sortedArtifacts(artifacts) {
+		row := cells(a)
+		if i >= markdownTableMaxRows || buf.Len()-start >= markdownTableMaxBytes {
+			more := make([]string, len(row))
+			more[0] = fmt.Sprintf("…and %d more", len(artifacts)-i)
+			fmt.Fprintf(buf, "| %s |\n", strings.Join(more, " | "))
+			return
+		}
+		fmt.Fprintf(buf, "| %s |\n", strings.Join(row, " | "))
+	}
+}
+
+func markdownCell(s string) string {
+	return markdownCellEscaper.Replace(s)
+}
+
+func sortedArtifacts(artifacts []*artifact.Artifact) []*artifact.Artifact {
+	sorted := append([]*artifact.Artifact{}, artifacts...)
+	sort.Sort(artifactsByDest(sorted))
+	return sorted
+}
+
+type artifactsByDest []*artifact.Artifact
+
+func (ad artifactsByDest) Len() int      { return len(ad) }
+func (ad artifactsByDest) Swap(i, j int) { ad[i], ad[j] = ad[j], ad[i] }
+func (ad artifactsByDest) Less(i, j int) bool {
+	if ad[i].FullDest() != ad[j].FullDest() {
+		return ad[i].FullDest() < ad[j].FullDest()
+	}
+	return ad[i].UploadResult.Provider < ad[j].UploadResult.Provider
+}
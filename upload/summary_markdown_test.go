@@ -0,0 +1,82 @@
+package upload
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/travis-ci/artifacts/artifact"
+)
+
+func TestUploaderSummaryMarkdown(t *testing.T) {
+	opts := NewOptions()
+	opts.Paths = []string{testArtifactPathDir}
+	opts.TargetPaths = []string{"artifacts/1"}
+	opts.FailurePolicy = FailurePolicyIgnore
+	opts.BucketName = "foo"
+	opts.S3Region = "us-east-1"
+	opts.SummaryMarkdown = filepath.Join(testTmp, "summary.md")
+
+	err := ioutil.WriteFile(opts.SummaryMarkdown, []byte("# Job\n\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{
+		&recordingProvider{name: "s3"},
+		&recordingProvider{name: "failing"},
+	}
+
+	err = u.Upload()
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(opts.SummaryMarkdown)
+	if err != nil {
+		t.Fatalf("failed to read summary: %v", err)
+	}
+
+	summary := string(b)
+	for _, expected := range []string{
+		"# Job\n\n### Artifacts\n",
+		"| Artifact | Provider | Size | Content type |",
+		"](https://s3.amazonaws.com/foo/artifacts/1/",
+		"#### Failed",
+		errUploadFailed.Error(),
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Fatalf("summary missing %q: %q", expected, summary)
+		}
+	}
+}
+
+func TestUploaderSummaryMarkdownTruncates(t *testing.T) {
+	u := newUploader(NewOptions(), getPanicLogger())
+
+	failed := []*artifact.Artifact{}
+	for i := 0; i < markdownTableMaxRows+3; i++ {
+		a := artifact.New("artifacts/1", testArtifactPaths[0].Path, fmt.Sprintf("[%d]`x`.txt", i), &artifact.Options{})
+		a.UploadResult.Provider = "null"
+		a.UploadResult.Err = errUploadFailed
+		failed = append(failed, a)
+	}
+
+	summary := string(u.summaryMarkdown(nil, failed))
+	for _, expected := range []string{
+		"| artifacts/1/\\[0\\]\\`x\\`.txt | null |",
+		"| …and 3 more |  |  |  |\n",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Fatalf("summary missing %q: %q", expected, summary)
+		}
+	}
+
+	// the header, separator, rows shown and the one for the rest
+	if strings.Count(summary, "\n| ") != markdownTableMaxRows+3 {
+		t.Fatalf("rows %v != %v", strings.Count(summary, "\n| "), markdownTableMaxRows+3)
+	}
+}
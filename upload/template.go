@@ -0,0 +1,49 @@
+package upload
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/travis-ci/artifacts/path"
+)
+
+// templateContext is what target path templates may refer to
+func (opts *Options) templateContext() *path.Context {
+	return &path.Context{
+		RepoSlug:    opts.RepoSlug,
+		BuildNumber: opts.BuildNumber,
+		BuildID:     opts.BuildID,
+		JobNumber:   opts.JobNumber,
+		JobID:       opts.JobID,
+		Branch:      opts.Branch,
+		Commit:      opts.Commit,
+		Tag:         opts.Tag,
+		OS:          opts.OS,
+		Now:         time.Now(),
+	}
+}
+
+// validateTemplates expands the target paths and each path's "to" so
+// that mistakes show up before anything is uploaded
+func (opts *Options) validateTemplates() error {
+	ctx := opts.templateContext()
+
+	for _, targetPath := range opts.TargetPaths {
+		if _, err := path.Expand(targetPath, ctx); err != nil {
+			return fmt.Errorf("invalid target path: %v", err)
+		}
+	}
+
+	for _, s := range opts.Paths {
+		p, err := path.Parse(opts.WorkingDir, s)
+		if err != nil {
+			return err
+		}
+
+		if _, err := path.Expand(p.To, ctx); err != nil {
+			return fmt.Errorf("invalid path %q: %v", s, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package upload
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOptionsValidateTemplates(t *testing.T) {
+	opts := NewOptions()
+	opts.DryRun = true
+	opts.TargetPaths = []string{"artifacts/{{ .Branch | slugfy }}"}
+
+	err := opts.Validate()
+	if err == nil || !strings.Contains(err.Error(), "slugfy") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	opts.TargetPaths = []string{"artifacts"}
+	opts.Paths = []string{"build/:{{ .Nope }}"}
+	if opts.Validate() == nil {
+		t.Fatalf("invalid path template was valid")
+	}
+}
+
+func TestNewUploaderExpandsTemplates(t *testing.T) {
+	opts := NewOptions()
+	opts.Branch = "feature/Foo"
+	opts.Commit = "0123456789abcdef"
+	opts.TargetPaths = []string{"artifacts/{{ .Branch | slugify }}/{{ .Commit | truncate 7 }}"}
+	opts.Paths = []string{"build/:{{ .Branch | lower }}"}
+
+	u := newUploader(opts, getPanicLogger())
+	if opts.TargetPaths[0] != "artifacts/feature-foo/0123456" {
+		t.Fatalf("%v != artifacts/feature-foo/0123456", opts.TargetPaths[0])
+	}
+
+	paths := u.Paths.All()
+	if len(paths) != 1 || paths[0].To != "feature/foo" {
+		t.Fatalf("unexpected paths %v", paths)
+	}
+}
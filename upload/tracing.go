@@ -0,0 +1,76 @@
+package upload
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/tracing"
+)
+
+const (
+	tracingServiceName = "artifacts"
+)
+
+func newTracer(opts *Options, log *logrus.Logger) *tracing.Tracer {
+	if opts.TracingEndpoint == "" {
+		return nil
+	}
+
+	tracer, err := tracing.New(opts.TracingEndpoint, tracingServiceName, opts.Traceparent)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"traceparent": opts.Traceparent,
+			"err":         err,
+		}).Warn("ignoring invalid traceparent, starting new trace")
+	}
+
+	log.WithFields(logrus.Fields{
+		"endpoint":    opts.TracingEndpoint,
+		"traceparent": opts.Traceparent,
+	}).Debug("tracing enabled")
+
+	return tracer
+}
+
+func startArtifactSpan(tracer *tracing.Tracer, provider string, a *artifact.Artifact) *tracing.Span {
+	span := tracer.Start("artifact.upload", nil)
+	if span == nil {
+		return nil
+	}
+
+	size, _ := a.Size()
+	span.SetAttribute("artifact.source", a.Source)
+	span.SetAttribute("artifact.dest", a.FullDest())
+	span.SetAttribute("artifact.size", size)
+	span.SetAttribute("artifact.provider", provider)
+	return span
+}
+
+func endArtifactSpan(span *tracing.Span, a *artifact.Artifact) {
+	result := "failed"
+	if a.UploadResult.OK {
+		result = "ok"
+	}
+
+	span.SetAttribute("artifact.result", result)
+	span.SetAttribute("artifact.retries", a.UploadResult.Retries)
+	span.SetError(a.UploadResult.Err)
+	span.End()
+}
+
+func tracedContentType(tracer *tracing.Tracer, parent *tracing.Span, a *artifact.Artifact) string {
+	span := tracer.Start("artifact.content_type", parent)
+	ctype := a.ContentType()
+	span.SetAttribute("artifact.content_type", ctype)
+	span.End()
+	return ctype
+}
+
+func (u *uploader) flushTraces() {
+	err := u.tracer.Flush()
+	if err != nil {
+		u.log.WithFields(logrus.Fields{
+			"endpoint": u.Opts.TracingEndpoint,
+			"err":      err,
+		}).Error("failed to export traces")
+	}
+}
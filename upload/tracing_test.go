@@ -0,0 +1,52 @@
+package upload
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploaderUploadExportsTraces(t *testing.T) {
+	body := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer ts.Close()
+
+	setUploaderEnv()
+	opts := NewOptions()
+	opts.TracingEndpoint = ts.URL
+	opts.Traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	log := getPanicLogger()
+	u := newUploader(opts, log)
+	u.Providers = []uploadProvider{newNullProvider(nil, log)}
+
+	err := u.Upload()
+	if err != nil {
+		t.Fatalf("failed to not really upload: %v", err)
+	}
+
+	for _, s := range []string{
+		`"traceId":"0af7651916cd43dd8448eb211c80319c"`,
+		`"parentSpanId":"b7ad6b7169203331"`,
+		`"name":"upload"`,
+		`"name":"walk"`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Fatalf("exported traces missing %s: %q", s, body)
+		}
+	}
+}
+
+func TestNewTracerDisabled(t *testing.T) {
+	opts := NewOptions()
+	opts.TracingEndpoint = ""
+
+	if newTracer(opts, getPanicLogger()) != nil {
+		t.Fatalf("tracer created without endpoint")
+	}
+}
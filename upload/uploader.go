@@ -2,32 +2,69 @@ package upload
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/dustin/go-humanize"
 	"github.com/mitchellh/goamz/s3"
 	"github.com/travis-ci/artifacts/artifact"
+	"github.com/travis-ci/artifacts/encryption"
+	"github.com/travis-ci/artifacts/journal"
+	"github.com/travis-ci/artifacts/logging"
 	"github.com/travis-ci/artifacts/path"
+	"github.com/travis-ci/artifacts/signing"
+	"github.com/travis-ci/artifacts/tracing"
 )
 
 const (
 	defaultPublicCacheControl = "public, max-age=315360000"
+
+	// uploadLogGroup names the collapsible group of log lines for the
+	// uploads, for the CI log formats
+	uploadLogGroup = "artifacts_upload"
+)
+
+var (
+	errUploadInterrupted = fmt.Errorf("upload interrupted")
+	errFeederStopped     = fmt.Errorf("artifact feeder stopped")
+	errProviderStopped   = fmt.Errorf("provider stopped before uploading")
 )
 
 type uploader struct {
 	Opts          *Options
 	Paths         *path.Set
 	RetryInterval time.Duration
-	Provider      uploadProvider
-
-	log       *logrus.Logger
-	curSize   *maxSizeTracker
-	startTime time.Time
+	Providers     []uploadProvider
+
+	log        *logrus.Logger
+	curSize    *maxSizeTracker
+	startTime  time.Time
+	inFlight   *inFlightSet
+	progress   *progressTracker
+	metrics    *uploadMetrics
+	tracer     *tracing.Tracer
+	rules      artifact.Rules
+	feedErr    error
+	fed        chan struct{}
+	returned   []chan struct{}
+	dropped    []*artifact.Artifact
+	incomplete bool
+	journal    *journal.Journal
+	mirror     *mirror
+	latest     *latestSet
+	signatures *signatureSet
+	checksums  *checksumSet
+	publishDir string
+	planOut    io.Writer
+	signals    chan os.Signal
+	stop       chan struct{}
 }
 
 type maxSizeTracker struct {
@@ -35,14 +72,40 @@ type maxSizeTracker struct {
 	Current uint64
 }
 
+type inFlightSet struct {
+	sync.Mutex
+	artifacts map[*artifact.Artifact]bool
+}
+
+func (ifs *inFlightSet) Add(a *artifact.Artifact) {
+	ifs.Lock()
+	defer ifs.Unlock()
+	ifs.artifacts[a] = true
+}
+
+func (ifs *inFlightSet) Remove(a *artifact.Artifact) {
+	ifs.Lock()
+	defer ifs.Unlock()
+	delete(ifs.artifacts, a)
+}
+
+// AbortAll aborts every in-flight artifact and returns how many
+// were aborted
+func (ifs *inFlightSet) AbortAll() int {
+	ifs.Lock()
+	defer ifs.Unlock()
+	for a := range ifs.artifacts {
+		a.Abort()
+	}
+	return len(ifs.artifacts)
+}
+
 // Upload does the deed!
 func Upload(opts *Options, log *logrus.Logger) error {
 	return newUploader(opts, log).Upload()
 }
 
 func newUploader(opts *Options, log *logrus.Logger) *uploader {
-	var provider uploadProvider
-
 	if opts.CacheControl == "" {
 		opts.CacheControl = defaultPublicCacheControl
 	}
@@ -51,36 +114,71 @@ func newUploader(opts *Options, log *logrus.Logger) *uploader {
 		opts.Provider = "s3"
 	}
 
-	switch opts.Provider {
-	case "artifacts":
-		provider = newArtifactsProvider(opts, log)
-	case "s3":
-		provider = newS3Provider(opts, log)
-	case "null":
-		provider = newNullProvider(nil, log)
-	default:
-		log.WithFields(logrus.Fields{
-			"provider": opts.Provider,
-		}).Warn("unrecognized provider, using s3 instead")
-		provider = newS3Provider(opts, log)
+	if opts.detectedCI != "" {
+		log.WithField("ci", opts.detectedCI).Debug("detected CI environment")
+	}
+
+	tracer := newTracer(opts, log)
+	j := openJournal(opts, log)
+
+	providers := []uploadProvider{}
+	for _, name := range opts.ProviderNames() {
+		providers = append(providers, newProvider(name, opts, tracer, j, log))
 	}
 
 	u := &uploader{
-		Opts:     opts,
-		Paths:    path.NewSet(),
-		Provider: provider,
+		Opts:      opts,
+		Paths:     path.NewSet(),
+		Providers: providers,
 
 		log:       log,
 		startTime: time.Now(),
+		inFlight:  &inFlightSet{artifacts: map[*artifact.Artifact]bool{}},
+		progress:  newProgressTracker(),
+		metrics:   newUploadMetrics(),
+		tracer:    tracer,
+		journal:   j,
+		planOut:   os.Stdout,
+		signals:   make(chan os.Signal, 2),
+		stop:      make(chan struct{}),
+	}
+
+	rules, err := opts.LoadContentRules()
+	if err != nil {
+		log.WithField("err", err).Warn("ignoring invalid content rules")
+	} else {
+		u.rules = rules
 	}
 
+	ctx := opts.templateContext()
+	targetPaths := []string{}
+	for _, targetPath := range opts.TargetPaths {
+		expanded, err := path.Expand(targetPath, ctx)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"target_path": targetPath,
+				"err":         err,
+			}).Warn("skipping invalid target path")
+			continue
+		}
+		targetPaths = append(targetPaths, expanded)
+	}
+	opts.TargetPaths = targetPaths
+
 	for _, s := range opts.Paths {
-		parts := strings.SplitN(s, ":", 2)
-		if len(parts) < 2 {
-			parts = append(parts, "")
+		p, err := path.Parse(opts.WorkingDir, s)
+		if err == nil {
+			p.To, err = path.Expand(p.To, ctx)
+		}
+
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"path": s,
+				"err":  err,
+			}).Warn("skipping invalid path")
+			continue
 		}
 
-		p := path.New(opts.WorkingDir, parts[0], parts[1])
 		log.WithFields(logrus.Fields{"path": p}).Debug("adding path")
 		u.Paths.Add(p)
 	}
@@ -88,23 +186,153 @@ func newUploader(opts *Options, log *logrus.Logger) *uploader {
 	return u
 }
 
+func newProvider(name string, opts *Options, tracer *tracing.Tracer, j *journal.Journal, log *logrus.Logger) uploadProvider {
+	switch name {
+	case "artifacts":
+		ap := newArtifactsProvider(opts, log)
+		ap.tracer = tracer
+		return ap
+	case "s3":
+		s3p := newS3Provider(opts, log)
+		s3p.tracer = tracer
+		s3p.journal = j
+		return s3p
+	case "null":
+		return newNullProvider(nil, log)
+	default:
+		log.WithFields(logrus.Fields{
+			"provider": name,
+		}).Warn("unrecognized provider, using s3 instead")
+		s3p := newS3Provider(opts, log)
+		s3p.tracer = tracer
+		s3p.journal = j
+		return s3p
+	}
+}
+
+func (u *uploader) providerNames() []string {
+	names := []string{}
+	for _, p := range u.Providers {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
 func (u *uploader) Upload() error {
 	u.log.Debug("starting upload")
 	u.startTime = time.Now()
+	u.progress = newProgressTracker()
+
+	if u.Opts.Delete {
+		m, err := newMirror(u.Opts, u.log)
+		if err != nil {
+			return err
+		}
+		u.mirror = m
+	}
+
+	if u.Opts.latestAlias() != "" {
+		u.latest = &latestSet{}
+	}
+
+	if names := u.Opts.checksumNames(); len(names) > 0 {
+		u.checksums = newChecksumSet(names)
+		for _, dest := range u.checksumDests() {
+			u.mirror.Seen(dest)
+		}
+		for _, dest := range u.provenanceDests() {
+			u.mirror.Seen(dest)
+		}
+	}
+
+	if u.Opts.EncryptionPassphrase != "" {
+		key, err := encryption.NewKey(u.Opts.EncryptionPassphrase)
+		if err != nil {
+			return err
+		}
+		for _, provider := range u.Providers {
+			if s3p, ok := provider.(*s3Provider); ok {
+				s3p.encryption = key
+			}
+		}
+	}
+
+	if u.Opts.SigningKey != "" {
+		ss, err := newSignatureSet(u.Opts.SigningKey)
+		if err != nil {
+			return err
+		}
+		defer ss.Remove()
+		u.signatures = ss
+	}
+
+	if u.Opts.DryRun {
+		return u.dryRun()
+	}
+
 	done := make(chan bool)
 	allDone := uint64(0)
-	inChan := u.files()
+	workers := u.Opts.Concurrency * uint64(len(u.Providers))
 	outChan := make(chan *artifact.Artifact)
 	failed := []*artifact.Artifact{}
+	completed := []*artifact.Artifact{}
+	summary := newUploadSummary(u.providerNames())
+	interrupted := false
+
+	var graceTimer <-chan time.Time
+
+	signal.Notify(u.signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(u.signals)
+	defer u.flushTraces()
+	defer u.saveJournal()
+	defer u.writeMetrics()
+	defer u.removePublished()
+
+	runSpan := u.tracer.StartRoot("upload")
+	runSpan.SetAttribute("provider", strings.Join(u.providerNames(), ","))
+	runSpan.SetAttribute("repo_slug", u.Opts.RepoSlug)
+	runSpan.SetAttribute("job_number", u.Opts.JobNumber)
+	defer func() {
+		runSpan.SetAttribute("artifacts.completed", len(completed))
+		runSpan.SetAttribute("artifacts.failed", len(failed))
+		if interrupted {
+			runSpan.SetError(errUploadInterrupted)
+		} else if len(failed) > 0 {
+			runSpan.SetError(fmt.Errorf("%d artifacts failed to upload", len(failed)))
+		} else {
+			runSpan.SetError(nil)
+		}
+		runSpan.End()
+	}()
+
+	// the feeder starts walking right away, so the root span has to exist
+	// before its walk spans do
+	inChans := u.files()
 
 	defer func() {
+		if interrupted {
+			for _, a := range completed {
+				u.log.WithFields(logrus.Fields{
+					"dest": a.FullDest(),
+				}).Info(fmt.Sprintf("completed before shutdown: %s", a.Source))
+			}
+		}
+
+		u.log.WithField(logging.GroupEndField, uploadLogGroup).Info(
+			fmt.Sprintf("done uploading %d artifacts", len(completed)+len(failed)))
+
+		summary.Log(u.log)
+		u.writeSummaryMarkdown(completed, failed)
+
 		if len(failed) == 0 {
 			return
 		}
 
 		for _, a := range failed {
 			u.log.WithFields(logrus.Fields{
-				"err": a.UploadResult.Err,
+				"provider": a.UploadResult.Provider,
+				"dest":     a.FullDest(),
+				"err":      a.UploadResult.Err,
 			}).Error(fmt.Sprintf("failed to upload: %s", a.Source))
 		}
 	}()
@@ -116,42 +344,166 @@ func (u *uploader) Upload() error {
 	}).Info("uploading with settings")
 
 	u.log.WithFields(logrus.Fields{
-		"working_dir":  u.Opts.WorkingDir,
-		"target_paths": u.Opts.TargetPaths,
-		"concurrency":  u.Opts.Concurrency,
-		"max_size":     u.Opts.MaxSize,
-		"retries":      u.Opts.Retries,
+		"working_dir":      u.Opts.WorkingDir,
+		"target_paths":     u.Opts.TargetPaths,
+		"concurrency":      u.Opts.Concurrency,
+		"max_size":         u.Opts.MaxSize,
+		"retries":          u.Opts.Retries,
+		"shutdown_timeout": u.Opts.ShutdownTimeout,
+		"providers":        u.providerNames(),
+		"failure_policy":   u.Opts.FailurePolicy,
+		"symlinks":         u.Opts.Symlinks,
+		"hidden_files":     u.Opts.HiddenFiles,
+		"delete":           u.Opts.Delete,
+		"ci":               u.Opts.detectedCI,
 	}).Debug("other upload settings")
 
-	for i := uint64(0); i < u.Opts.Concurrency; i++ {
-		u.log.WithFields(logrus.Fields{
-			"uploader": i,
-		}).Debug("starting uploader worker")
+	u.log.WithField(logging.GroupStartField, uploadLogGroup).Info("uploading artifacts")
 
-		go u.Provider.Upload(fmt.Sprintf("%d", i), u.Opts, inChan, outChan, done)
+	if u.Opts.ProgressInterval > 0 && u.log.Level >= logrus.InfoLevel {
+		reporter := newProgressReporter(u.progress, u.log,
+			time.Duration(u.Opts.ProgressInterval)*time.Second)
+		reporter.Start()
+		defer reporter.Stop()
+	}
+
+	for j, provider := range u.Providers {
+		var providerWorkers sync.WaitGroup
+		for i := uint64(0); i < u.Opts.Concurrency; i++ {
+			u.log.WithFields(logrus.Fields{
+				"uploader": i,
+				"provider": provider.Name(),
+			}).Debug("starting uploader worker")
+
+			providerWorkers.Add(1)
+			go func(provider uploadProvider, id string, in chan *artifact.Artifact) {
+				defer providerWorkers.Done()
+				provider.Upload(id, u.Opts, in, outChan, done)
+			}(provider, fmt.Sprintf("%s-%d", provider.Name(), i), inChans[j])
+		}
+
+		// a provider can give up before its channel is closed (bad
+		// credentials, missing bucket), so let the feeder know to stop
+		// sending it anything
+		go func(returned chan struct{}) {
+			providerWorkers.Wait()
+			close(returned)
+		}(u.returned[j])
+	}
+
+	record := func(a *artifact.Artifact) {
+		u.inFlight.Remove(a)
+		u.progress.Done(a)
+		u.journalResult(a)
+		u.metrics.Record(a.UploadResult.Provider, a)
+		summary.Record(a)
+		if a.UploadResult.OK {
+			completed = append(completed, a)
+		} else {
+			failed = append(failed, a)
+		}
 	}
 
 	for {
 		select {
 		case outArtifact := <-outChan:
-			if outArtifact != nil && !outArtifact.UploadResult.OK {
-				failed = append(failed, outArtifact)
+			if outArtifact == nil {
+				continue
 			}
+
+			record(outArtifact)
 		case <-done:
 			allDone++
-			if allDone >= u.Opts.Concurrency {
-				return nil
+			if allDone >= workers {
+				if interrupted {
+					return errUploadInterrupted
+				}
+				// with every provider gone the feeder may still be
+				// walking, and whatever it dropped counts as failed
+				<-u.fed
+				for _, a := range u.dropped {
+					record(a)
+				}
+				if u.feedErr != nil {
+					return u.feedErr
+				}
+				if len(failed) == 0 {
+					u.removeJournal()
+					if err := u.publishChecksums(); err != nil {
+						return err
+					}
+					if err := u.publishProvenance(); err != nil {
+						return err
+					}
+					if err := u.deleteStale(); err != nil {
+						return err
+					}
+					if err := u.publishLatest(); err != nil {
+						return err
+					}
+				} else if u.mirror != nil {
+					u.log.Warn("not deleting stale artifacts as some failed to upload")
+				}
+				return summary.Err(u.Opts.FailurePolicy)
+			}
+		case sig := <-u.signals:
+			if interrupted {
+				u.log.WithField("signal", sig).Warn("received second signal, aborting in-flight uploads")
+				u.abortInFlight()
+				continue
 			}
+
+			interrupted = true
+			grace := time.Duration(u.Opts.ShutdownTimeout) * time.Second
+			u.log.WithFields(logrus.Fields{
+				"signal":       sig,
+				"grace_period": grace,
+			}).Warn("received signal, waiting for in-flight uploads to finish")
+
+			close(u.stop)
+			graceTimer = time.After(grace)
+		case <-graceTimer:
+			u.log.Warn("shutdown timeout exceeded, aborting in-flight uploads")
+			u.abortInFlight()
 		}
 	}
 
 	return nil
 }
 
-func (u *uploader) artifactFeederLoop(path *path.Path, artifacts chan *artifact.Artifact) error {
+func (u *uploader) abortInFlight() {
+	n := u.inFlight.AbortAll()
+	u.log.WithField("count", n).Debug("aborted in-flight uploads")
+}
+
+// markIncomplete records that the walk skipped or stopped short of
+// something, so the uploads do not reflect the paths exactly
+func (u *uploader) markIncomplete() {
+	u.incomplete = true
+	u.mirror.Incomplete()
+}
+
+func (u *uploader) stopped() bool {
+	select {
+	case <-u.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (u *uploader) artifactFeederLoop(path *path.Path, artifacts []chan *artifact.Artifact) error {
 	to, from, root := path.To, path.From, path.Root
 	u.log.WithField("path", path).Debug("incoming path")
 
+	walkSpan := u.tracer.Start("walk", nil)
+	walkSpan.SetAttribute("path", path.Fullpath())
+	queued := 0
+	defer func() {
+		walkSpan.SetAttribute("artifacts.queued", queued)
+		walkSpan.End()
+	}()
+
 	if path.IsDir() {
 		root = filepath.Join(root, from)
 		u.log.WithField("root", root).Debug("path is dir, so setting root to root+from")
@@ -164,14 +516,78 @@ func (u *uploader) artifactFeederLoop(path *path.Path, artifacts chan *artifact.
 		BuildID:     u.Opts.BuildID,
 		JobNumber:   u.Opts.JobNumber,
 		JobID:       u.Opts.JobID,
+
+		CacheControl: u.Opts.CacheControl,
+		ContentType:  path.ContentType,
+		Rules:        u.rules,
 	}
 
-	filepath.Walk(path.Fullpath(), func(source string, info os.FileInfo, err error) error {
-		if info != nil && info.IsDir() {
-			u.log.WithField("path", source).Debug("skipping directory")
-			return nil
+	if path.Perm != "" {
+		artifactOpts.Perm = s3.ACL(path.Perm)
+	}
+
+	if path.CacheControl != "" {
+		// the path's own setting wins over any content rule
+		artifactOpts.Rules = append(artifact.Rules{
+			&artifact.Rule{CacheControl: path.CacheControl},
+		}, u.rules...)
+	}
+
+	signatureOpts := *artifactOpts
+	signatureOpts.ContentType = plainTextContentType
+
+	// queue hands a copy of the artifact to each provider, unless it
+	// is already up there, listing it in the checksum files if asked to
+	queue := func(targetPath, source, dest, linkTarget string, size uint64, opts *artifact.Options, checksum bool) error {
+		for j, provider := range u.Providers {
+			a := artifact.New(targetPath, source, dest, opts)
+			a.SymlinkTarget = linkTarget
+			a.UploadResult.Provider = provider.Name()
+
+			u.mirror.Seen(a.FullDest())
+			if provider.Name() == "s3" {
+				u.latest.Add(a)
+			}
+			if checksum && linkTarget == "" {
+				u.checksums.Add(a, size)
+			}
+
+			if provider.Name() == "s3" && !u.Opts.CAS && linkTarget == "" &&
+				u.mirror.Unchanged(a.FullDest(), a.Source, size) {
+				u.log.WithFields(logrus.Fields{
+					"provider": provider.Name(),
+					"dest":     a.FullDest(),
+				}).Info(fmt.Sprintf("unchanged: %s", a.Source))
+				continue
+			}
+
+			if u.journal.IsComplete(provider.Name(), a.FullDest(), a.Source) {
+				u.log.WithFields(logrus.Fields{
+					"provider": provider.Name(),
+					"dest":     a.FullDest(),
+				}).Info(fmt.Sprintf("already uploaded: %s", a.Source))
+				continue
+			}
+
+			u.journalChecksum(a, size)
+			u.progress.AddArtifact(a, size)
+			u.inFlight.Add(a)
+			select {
+			case artifacts[j] <- a:
+				queued++
+			case <-u.returned[j]:
+				a.UploadResult.OK = false
+				a.UploadResult.Err = errProviderStopped
+				u.dropped = append(u.dropped, a)
+			case <-u.stop:
+				u.inFlight.Remove(a)
+				return errFeederStopped
+			}
 		}
+		return nil
+	}
 
+	err := u.walk(path.Fullpath(), func(source string, info os.FileInfo, linkTarget string) error {
 		relPath := strings.Replace(strings.Replace(source, root, "", -1), root+"/", "", -1)
 		dest := relPath
 		if len(to) > 0 {
@@ -182,14 +598,37 @@ func (u *uploader) artifactFeederLoop(path *path.Path, artifacts chan *artifact.
 			}
 		}
 
+		signature, signatureSize, err := u.signatures.Sign(source, dest, linkTarget)
+		if err != nil {
+			// the rest of the files still go up, but this one counts
+			// as failed everywhere it would have gone
+			u.log.WithFields(logrus.Fields{
+				"source": source,
+				"err":    err,
+			}).Error(fmt.Sprintf("failed to sign: %s", source))
+			u.markIncomplete()
+
+			for _, targetPath := range u.Opts.TargetPaths {
+				for _, provider := range u.Providers {
+					a := artifact.New(targetPath, source, dest, artifactOpts)
+					a.SymlinkTarget = linkTarget
+					a.UploadResult.Provider = provider.Name()
+					a.UploadResult.Err = err
+					u.progress.AddArtifact(a, 0)
+					u.dropped = append(u.dropped, a)
+				}
+			}
+			return nil
+		}
+
 		for _, targetPath := range u.Opts.TargetPaths {
 			err := func() error {
 				u.curSize.Lock()
 				defer u.curSize.Unlock()
 
-				a := artifact.New(targetPath, source, dest, artifactOpts)
-
-				size, err := a.Size()
+				sized := artifact.New(targetPath, source, dest, artifactOpts)
+				sized.SymlinkTarget = linkTarget
+				size, err := sized.Size()
 				if err != nil {
 					return err
 				}
@@ -204,14 +643,22 @@ func (u *uploader) artifactFeederLoop(path *path.Path, artifacts chan *artifact.
 				}
 
 				if u.curSize.Current > u.Opts.MaxSize {
+					for _, provider := range u.Providers {
+						u.metrics.Skip(provider.Name())
+					}
 					msg := "max-size would be exceeded"
 					u.log.WithFields(logFields).Error(msg)
 					return fmt.Errorf(msg)
 				}
 
 				u.log.WithFields(logFields).Debug("queueing artifact")
-				artifacts <- a
-				return nil
+
+				err = queue(targetPath, source, dest, linkTarget, size, artifactOpts, true)
+				if err != nil || signature == "" {
+					return err
+				}
+
+				return queue(targetPath, signature, dest+signing.Ext, "", signatureSize, &signatureOpts, false)
 			}()
 			if err != nil {
 				return err
@@ -220,15 +667,31 @@ func (u *uploader) artifactFeederLoop(path *path.Path, artifacts chan *artifact.
 		return nil
 	})
 
+	if err != nil {
+		u.markIncomplete()
+	}
+
+	if _, ok := err.(*symlinkError); ok {
+		return err
+	}
+
 	return nil
 }
 
-func (u *uploader) artifactFeeder(artifacts chan *artifact.Artifact) error {
+func (u *uploader) artifactFeeder(artifacts []chan *artifact.Artifact) error {
 	u.curSize = &maxSizeTracker{Current: uint64(0)}
 
 	i := 0
 	for _, path := range u.Paths.All() {
-		u.artifactFeederLoop(path, artifacts)
+		if u.stopped() {
+			u.log.Debug("feeder stopped, skipping remaining paths")
+			break
+		}
+
+		err := u.artifactFeederLoop(path, artifacts)
+		if err != nil && u.feedErr == nil {
+			u.feedErr = err
+		}
 		i++
 	}
 
@@ -238,12 +701,23 @@ func (u *uploader) artifactFeeder(artifacts chan *artifact.Artifact) error {
 		"time_elapsed": time.Since(u.startTime),
 	}).Debug("done feeding artifacts")
 
-	close(artifacts)
+	for _, ch := range artifacts {
+		close(ch)
+	}
+	close(u.fed)
 	return nil
 }
 
-func (u *uploader) files() chan *artifact.Artifact {
-	artifacts := make(chan *artifact.Artifact)
+// files starts feeding artifacts and returns one channel per provider,
+// each of which receives its own copy of every artifact
+func (u *uploader) files() []chan *artifact.Artifact {
+	artifacts := []chan *artifact.Artifact{}
+	u.fed = make(chan struct{})
+	u.returned = []chan struct{}{}
+	for i := 0; i < len(u.Providers); i++ {
+		artifacts = append(artifacts, make(chan *artifact.Artifact))
+		u.returned = append(u.returned, make(chan struct{}))
+	}
 	go u.artifactFeeder(artifacts)
 	return artifacts
 }
@@ -1,10 +1,17 @@
 package upload
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/travis-ci/artifacts/artifact"
 )
 
 var (
@@ -36,7 +43,7 @@ func getTestUploader() *uploader {
 
 	log := getPanicLogger()
 	u := newUploader(NewOptions(), log)
-	u.Provider = newNullProvider(nil, log)
+	u.Providers = []uploadProvider{newNullProvider(nil, log)}
 	return u
 }
 
@@ -80,9 +87,9 @@ func TestNewUploaderProviderOptions(t *testing.T) {
 	for opt, name := range testOptsProviderCases {
 		opts.Provider = opt
 		u := newUploader(opts, getPanicLogger())
-		if u.Provider.Name() != name {
+		if u.Providers[0].Name() != name {
 			t.Fatalf("new uploader does not have %s provider: %q != %q",
-				name, u.Provider.Name(), name)
+				name, u.Providers[0].Name(), name)
 		}
 	}
 }
@@ -115,3 +122,265 @@ func TestUploaderUpload(t *testing.T) {
 		t.Errorf("failed to not really upload: %v", err)
 	}
 }
+
+type abortWaitingProvider struct{}
+
+func (awp *abortWaitingProvider) Upload(id string, opts *Options,
+	in chan *artifact.Artifact, out chan *artifact.Artifact, done chan bool) {
+
+	for a := range in {
+		for !a.Aborted() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		a.UploadResult.Err = artifact.ErrAborted
+		out <- a
+	}
+
+	done <- true
+}
+
+func (awp *abortWaitingProvider) Name() string {
+	return "abort-waiting"
+}
+
+func TestUploaderUploadInterrupted(t *testing.T) {
+	opts := NewOptions()
+	opts.Paths = []string{testArtifactPathDir}
+	opts.ShutdownTimeout = 0
+
+	u := newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{&abortWaitingProvider{}}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		u.signals <- syscall.SIGTERM
+	}()
+
+	errChan := make(chan error)
+	go func() { errChan <- u.Upload() }()
+
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatalf("interrupted upload took too long")
+	case err := <-errChan:
+		if err != errUploadInterrupted {
+			t.Fatalf("interrupted upload error %v != %v", err, errUploadInterrupted)
+		}
+	}
+}
+
+func TestUploaderUploadWritesMetricsFile(t *testing.T) {
+	u := getTestUploader()
+	u.Opts.MetricsFile = filepath.Join(testTmp, "artifacts.prom")
+
+	err := u.Upload()
+	if err != nil {
+		t.Fatalf("failed to not really upload: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(u.Opts.MetricsFile)
+	if err != nil {
+		t.Fatalf("failed to read metrics file: %v", err)
+	}
+
+	if !strings.Contains(string(b), "artifacts_run_duration_seconds") {
+		t.Fatalf("metrics file missing run duration: %q", string(b))
+	}
+}
+
+func TestNewUploaderMultipleProviders(t *testing.T) {
+	opts := NewOptions()
+	opts.Provider = "null, artifacts,null"
+
+	u := newUploader(opts, getPanicLogger())
+	if len(u.Providers) != 2 {
+		t.Fatalf("providers length %v != 2", len(u.Providers))
+	}
+
+	if u.Providers[0].Name() != "null" || u.Providers[1].Name() != "artifacts" {
+		t.Fatalf("unexpected providers %v", u.providerNames())
+	}
+}
+
+type recordingProvider struct {
+	sync.Mutex
+	name     string
+	uploaded []*artifact.Artifact
+}
+
+func (rp *recordingProvider) Upload(id string, opts *Options,
+	in chan *artifact.Artifact, out chan *artifact.Artifact, done chan bool) {
+
+	for a := range in {
+		rp.Lock()
+		rp.uploaded = append(rp.uploaded, a)
+		rp.Unlock()
+
+		a.UploadResult.OK = rp.name != "failing"
+		if !a.UploadResult.OK {
+			a.UploadResult.Err = errUploadFailed
+		}
+		out <- a
+	}
+
+	done <- true
+}
+
+func (rp *recordingProvider) Name() string {
+	return rp.name
+}
+
+func TestUploaderUploadFansOut(t *testing.T) {
+	for policy, shouldFail := range map[string]bool{
+		FailurePolicyIgnore: false,
+		FailurePolicyAll:    false,
+		FailurePolicyAny:    true,
+	} {
+		opts := NewOptions()
+		opts.Paths = []string{testArtifactPathDir}
+		opts.TargetPaths = []string{"artifacts/1"}
+		opts.FailurePolicy = policy
+
+		ok := &recordingProvider{name: "ok"}
+		failing := &recordingProvider{name: "failing"}
+
+		u := newUploader(opts, getPanicLogger())
+		u.Providers = []uploadProvider{ok, failing}
+
+		err := u.Upload()
+		if shouldFail && err == nil {
+			t.Fatalf("policy %q did not fail the upload", policy)
+		}
+
+		if !shouldFail && err != nil {
+			t.Fatalf("policy %q failed the upload: %v", policy, err)
+		}
+
+		if len(ok.uploaded) == 0 || len(ok.uploaded) != len(failing.uploaded) {
+			t.Fatalf("artifacts not fanned out: %v != %v",
+				len(ok.uploaded), len(failing.uploaded))
+		}
+
+		if ok.uploaded[0] == failing.uploaded[0] {
+			t.Fatalf("providers were given the same artifact")
+		}
+	}
+}
+
+type brokenProvider struct{}
+
+func (bp *brokenProvider) Upload(id string, opts *Options,
+	in chan *artifact.Artifact, out chan *artifact.Artifact, done chan bool) {
+
+	done <- true
+}
+
+func (bp *brokenProvider) Name() string {
+	return "broken"
+}
+
+func TestUploaderUploadProviderReturnsEarly(t *testing.T) {
+	opts := NewOptions()
+	opts.Paths = []string{testArtifactPathDir}
+	opts.TargetPaths = []string{"artifacts/1"}
+	opts.FailurePolicy = FailurePolicyAny
+
+	ok := &recordingProvider{name: "ok"}
+
+	u := newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{&brokenProvider{}, ok}
+
+	errChan := make(chan error)
+	go func() { errChan <- u.Upload() }()
+
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatalf("upload blocked on a provider that returned early")
+	case err := <-errChan:
+		if err == nil {
+			t.Fatalf("upload did not fail with a broken provider")
+		}
+	}
+
+	if len(ok.uploaded) == 0 || len(ok.uploaded) != len(u.dropped) {
+		t.Fatalf("uploaded %v != dropped %v", len(ok.uploaded), len(u.dropped))
+	}
+
+	for _, a := range u.dropped {
+		if a.UploadResult.Err != errProviderStopped {
+			t.Fatalf("%v != %v", a.UploadResult.Err, errProviderStopped)
+		}
+	}
+}
+
+func TestUploaderUploadSignFailure(t *testing.T) {
+	secret, _ := testSigningKeys(t)
+
+	opts := NewOptions()
+	opts.Paths = []string{testArtifactPathDir}
+	opts.TargetPaths = []string{"artifacts/1"}
+	opts.FailurePolicy = FailurePolicyAny
+
+	ss, err := newSignatureSet(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss.Remove()
+
+	ok := &recordingProvider{name: "ok"}
+
+	u := newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{ok}
+	u.signatures = ss
+
+	err = u.Upload()
+	if err == nil {
+		t.Fatalf("upload did not fail with unwritable signatures")
+	}
+
+	if len(u.dropped) == 0 {
+		t.Fatalf("no artifacts were recorded as failed")
+	}
+
+	for _, a := range u.dropped {
+		if a.UploadResult.Err == nil || a.UploadResult.OK {
+			t.Fatalf("%v was not recorded as failed", a.Source)
+		}
+	}
+}
+
+func TestUploaderUploadPathSettings(t *testing.T) {
+	opts := NewOptions()
+	opts.Perm = "private"
+	opts.CacheControl = "private"
+	opts.Paths = []string{testArtifactPathDir + ":site?permissions=public-read&content-type=text/plain"}
+	opts.TargetPaths = []string{"artifacts/1"}
+
+	rp := &recordingProvider{name: "ok"}
+
+	u := newUploader(opts, getPanicLogger())
+	u.Providers = []uploadProvider{rp}
+
+	err := u.Upload()
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if len(rp.uploaded) == 0 {
+		t.Fatalf("nothing was uploaded")
+	}
+
+	for _, a := range rp.uploaded {
+		if string(a.Perm) != "public-read" {
+			t.Fatalf("perm %v != public-read", a.Perm)
+		}
+
+		if a.CacheControl != "private" {
+			t.Fatalf("cache control %v != private", a.CacheControl)
+		}
+
+		if a.ContentType() != "text/plain" {
+			t.Fatalf("content type %v != text/plain", a.ContentType())
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package upload
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// SymlinksFollow uploads the targets of symlinks, walking symlinked
+	// directories unless doing so would cycle
+	SymlinksFollow = "follow"
+	// SymlinksSkip leaves symlinks out of the upload
+	SymlinksSkip = "skip"
+	// SymlinksError fails the run when a symlink is found
+	SymlinksError = "error"
+	// SymlinksPreserve uploads symlinks as empty objects that redirect
+	// to, and record in their metadata, the link target
+	SymlinksPreserve = "preserve"
+
+	// HiddenFilesInclude uploads dotfiles like any other file
+	HiddenFilesInclude = "include"
+	// HiddenFilesExclude leaves dotfiles and dot-directories out of the
+	// upload
+	HiddenFilesExclude = "exclude"
+)
+
+var (
+	symlinkPolicies = map[string]bool{
+		SymlinksFollow:   true,
+		SymlinksSkip:     true,
+		SymlinksError:    true,
+		SymlinksPreserve: true,
+	}
+
+	hiddenFilesPolicies = map[string]bool{
+		HiddenFilesInclude: true,
+		HiddenFilesExclude: true,
+	}
+)
+
+// symlinkError is returned by the walk when a symlink is found and the
+// symlink policy is SymlinksError
+type symlinkError struct {
+	Path string
+}
+
+func (e *symlinkError) Error() string {
+	return fmt.Sprintf("found symlink %s (symlinks: %s)", e.Path, SymlinksError)
+}
+
+// walkFunc is called for each regular file found by the walk, and for
+// each symlink when preserving symlinks, in which case linkTarget is the
+// target of the link
+type walkFunc func(source string, info os.FileInfo, linkTarget string) error
+
+// walk calls fn for each file under root according to the symlink and
+// hidden file policies, skipping special files like FIFOs and sockets
+func (u *uploader) walk(root string, fn walkFunc) error {
+	return u.walkPath(root, true, map[string]bool{}, fn)
+}
+
+func (u *uploader) walkPath(source string, isRoot bool, ancestors map[string]bool, fn walkFunc) error {
+	if u.stopped() {
+		return errFeederStopped
+	}
+
+	info, err := os.Lstat(source)
+	if err != nil {
+		u.log.WithFields(logrus.Fields{
+			"path": source,
+			"err":  err,
+		}).Warn("skipping unreadable path")
+		u.markIncomplete()
+		return nil
+	}
+
+	if !isRoot && u.Opts.HiddenFiles == HiddenFilesExclude && strings.HasPrefix(info.Name(), ".") {
+		u.log.WithField("path", source).Debug("skipping hidden path")
+		return nil
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		return u.walkSymlink(source, info, ancestors, fn)
+	}
+
+	if info.IsDir() {
+		return u.walkDir(source, ancestors, fn)
+	}
+
+	if !info.Mode().IsRegular() {
+		u.log.WithFields(logrus.Fields{
+			"path": source,
+			"mode": info.Mode().String(),
+		}).Debug("skipping special file")
+		return nil
+	}
+
+	return fn(source, info, "")
+}
+
+func (u *uploader) walkSymlink(source string, info os.FileInfo, ancestors map[string]bool, fn walkFunc) error {
+	target, err := os.Readlink(source)
+	if err != nil {
+		u.log.WithFields(logrus.Fields{
+			"path": source,
+			"err":  err,
+		}).Warn("skipping unreadable symlink")
+		u.markIncomplete()
+		return nil
+	}
+
+	logFields := logrus.Fields{
+		"path":     source,
+		"target":   target,
+		"symlinks": u.Opts.Symlinks,
+	}
+
+	switch u.Opts.Symlinks {
+	case SymlinksSkip:
+		u.log.WithFields(logFields).Debug("skipping symlink")
+		return nil
+	case SymlinksError:
+		u.log.WithFields(logFields).Error("found symlink")
+		return &symlinkError{Path: source}
+	case SymlinksPreserve:
+		u.log.WithFields(logFields).Debug("preserving symlink")
+		return fn(source, info, target)
+	}
+
+	targetInfo, err := os.Stat(source)
+	if err != nil {
+		logFields["err"] = err
+		u.log.WithFields(logFields).Warn("skipping dangling symlink")
+		return nil
+	}
+
+	if targetInfo.IsDir() {
+		u.log.WithFields(logFields).Debug("following symlinked directory")
+		return u.walkDir(source, ancestors, fn)
+	}
+
+	if !targetInfo.Mode().IsRegular() {
+		logFields["mode"] = targetInfo.Mode().String()
+		u.log.WithFields(logFields).Debug("skipping symlink to special file")
+		return nil
+	}
+
+	u.log.WithFields(logFields).Debug("following symlink")
+	return fn(source, targetInfo, "")
+}
+
+func (u *uploader) walkDir(source string, ancestors map[string]bool, fn walkFunc) error {
+	realPath, err := filepath.EvalSymlinks(source)
+	if err != nil {
+		realPath = source
+	}
+
+	if ancestors[realPath] {
+		u.log.WithFields(logrus.Fields{
+			"path":      source,
+			"real_path": realPath,
+		}).Warn("skipping symlink cycle")
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(source)
+	if err != nil {
+		u.log.WithFields(logrus.Fields{
+			"path": source,
+			"err":  err,
+		}).Warn("skipping unreadable directory")
+		u.markIncomplete()
+		return nil
+	}
+
+	ancestors[realPath] = true
+	defer delete(ancestors, realPath)
+
+	for _, entry := range entries {
+		err := u.walkPath(filepath.Join(source, entry.Name()), false, ancestors, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package upload
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func makeWalkTree(t *testing.T) string {
+	dir, err := ioutil.TempDir(testTmp, "walk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.txt", ".hidden", "sub/b.txt", ".git/config"} {
+		filename := filepath.Join(dir, name)
+		err = os.MkdirAll(filepath.Dir(filename), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(filename, []byte(name), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for link, target := range map[string]string{
+		"link.txt": "a.txt",
+		"linkdir":  "sub",
+		"sub/loop": "..",
+		"dangling": "nope",
+	} {
+		err = os.Symlink(target, filepath.Join(dir, link))
+		if err != nil {
+			t.Skipf("cannot create symlinks: %v", err)
+		}
+	}
+
+	if l, err := net.Listen("unix", filepath.Join(dir, "sock")); err == nil {
+		defer l.Close()
+		// keep the socket file around after closing the listener
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+	}
+
+	return dir
+}
+
+func walkedPaths(t *testing.T, u *uploader, dir string) ([]string, error) {
+	paths := []string{}
+	err := u.walk(dir, func(source string, info os.FileInfo, linkTarget string) error {
+		rel, err := filepath.Rel(dir, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if linkTarget != "" {
+			rel += " -> " + linkTarget
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, err
+}
+
+func TestUploaderWalkPolicies(t *testing.T) {
+	dir := makeWalkTree(t)
+
+	for _, tc := range []struct {
+		symlinks string
+		hidden   string
+		expected []string
+	}{
+		{
+			symlinks: SymlinksFollow,
+			hidden:   HiddenFilesInclude,
+			expected: []string{
+				".git/config", ".hidden", "a.txt", "link.txt",
+				"linkdir/b.txt", "sub/b.txt",
+			},
+		},
+		{
+			symlinks: SymlinksSkip,
+			hidden:   HiddenFilesExclude,
+			expected: []string{"a.txt", "sub/b.txt"},
+		},
+		{
+			symlinks: SymlinksPreserve,
+			hidden:   HiddenFilesExclude,
+			expected: []string{
+				"a.txt", "dangling -> nope", "link.txt -> a.txt",
+				"linkdir -> sub", "sub/b.txt", "sub/loop -> ..",
+			},
+		},
+	} {
+		opts := NewOptions()
+		opts.Symlinks = tc.symlinks
+		opts.HiddenFiles = tc.hidden
+
+		u := newUploader(opts, getPanicLogger())
+		paths, err := walkedPaths(t, u, dir)
+		if err != nil {
+			t.Fatalf("walk failed: %v", err)
+		}
+
+		if len(paths) != len(tc.expected) {
+			t.Fatalf("%s/%s: %v != %v", tc.symlinks, tc.hidden, paths, tc.expected)
+		}
+
+		for i, p := range paths {
+			if p != tc.expected[i] {
+				t.Fatalf("%s/%s: %v != %v", tc.symlinks, tc.hidden, paths, tc.expected)
+			}
+		}
+	}
+}
+
+func TestUploaderWalkSymlinksError(t *testing.T) {
+	dir := makeWalkTree(t)
+
+	opts := NewOptions()
+	opts.Symlinks = SymlinksError
+
+	u := newUploader(opts, getPanicLogger())
+	_, err := walkedPaths(t, u, dir)
+	if _, ok := err.(*symlinkError); !ok {
+		t.Fatalf("symlink did not fail the walk: %v", err)
+	}
+}